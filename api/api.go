@@ -0,0 +1,88 @@
+// Package api holds the json types of the node api. The node answers with them and the client decodes them, so
+// a client of the api needs neither the node nor its storage packages.
+package api
+
+import (
+	"time"
+
+	"../models"
+	"../transaction"
+)
+
+// Finality annotates a query entry with its position in the canonical chain
+type Finality struct {
+	BlockHeight   int32  `json:"blockHeight"`
+	BlockHash     string `json:"blockHash"`
+	Confirmations int32  `json:"confirmations"`
+	Finalized     bool   `json:"finalized"`
+}
+
+// ChainTransaction is a transaction of the canonical chain annotated with the address of the sender and its finality
+type ChainTransaction struct {
+	tx.Transaction
+	FromAddress string `json:"fromAddress"`
+	Finality
+}
+
+// ChainMerit is a merit of the canonical chain annotated with the address of the applicant and its finality
+type ChainMerit struct {
+	models.SignedMerit
+	Address string `json:"address"`
+	Finality
+}
+
+// MinerBalanceData holds the fees credited to a producer, FinalizedBalance only counts finalized blocks
+type MinerBalanceData struct {
+	Balance          float32 `json:"balance"`
+	FinalizedBalance float32 `json:"finalizedBalance"`
+}
+
+// NodeInfoData describes the identity and the chain state of a node, ChainHash is the hash printed by /show
+type NodeInfoData struct {
+	Id              int32  `json:"id"`
+	Addr            string `json:"addr"`
+	Signer          string `json:"signer"`
+	Producer        string `json:"producer"`
+	Height          int32  `json:"height"`
+	FinalizedHeight int32  `json:"finalizedHeight"`
+	FinalizedHash   string `json:"finalizedHash"`
+	ChainHash       string `json:"chainHash"`
+	Bootstrapped    bool   `json:"bootstrapped"`
+	Peers           int    `json:"peers"`
+}
+
+// PeerReputation is the reputation of a single peer
+type PeerReputation struct {
+	Addr        string    `json:"addr"`
+	Score       int32     `json:"score"`
+	Banned      bool      `json:"banned"`
+	BannedUntil time.Time `json:"bannedUntil,omitempty"`
+}
+
+// Block presents a block, the transactions are keyed by their hash
+type Block struct {
+	Version    int32                     `json:"version"`
+	Nonce      string                    `json:"nonce"`
+	Height     int32                     `json:"height"`
+	Timestamp  int64                     `json:"timestamp"`
+	Hash       string                    `json:"hash"`
+	ParentHash string                    `json:"parentHash"`
+	Size       int32                     `json:"size"`
+	Producer   string                    `json:"producer"`
+	Signature  string                    `json:"signature,omitempty"`
+	Value      map[string]tx.Transaction `json:"value"`
+}
+
+// Events streamed to the subscribers of /events
+const (
+	EVENT_PENDING = "pending" // the transaction entered the mempool
+	EVENT_MINED   = "mined"   // the transaction was mined into a block of the canonical chain
+	EVENT_REORGED = "reorged" // the block of the transaction left the canonical chain
+)
+
+// Event tells a subscriber what happened to a transaction. Finality is the block a mined transaction is in and the
+// block a reorged transaction was in, it is empty for pending transactions
+type Event struct {
+	Event string `json:"event"`
+	ChainTransaction
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"../api"
+	"../transaction"
+)
+
+// Transaction states reported by Status
+const (
+	STATE_PENDING   = "pending"
+	STATE_MINED     = "mined"
+	STATE_FINALIZED = "finalized"
+)
+
+// TransactionStatus is the state of a transaction, Finality is only set once the transaction is mined
+type TransactionStatus struct {
+	Hash  string `json:"hash"`
+	State string `json:"state"`
+	api.Finality
+}
+
+// addressPath appends the address a query is restricted to, all accounts are queried if address is empty
+func addressPath(path string, address string) string {
+	if address == "" {
+		return path
+	}
+	return path + "?address=" + url.QueryEscape(address)
+}
+
+// Transactions returns the transactions of the canonical chain, only those sent by address if it is not empty
+func (client *Client) Transactions(ctx context.Context, address string) ([]api.ChainTransaction, error) {
+	transactions := make([]api.ChainTransaction, 0)
+	return transactions, client.getJSON(ctx, addressPath("/transactions", address), &transactions)
+}
+
+// Merits returns the merits of the canonical chain, only those published by address if it is not empty
+func (client *Client) Merits(ctx context.Context, address string) ([]api.ChainMerit, error) {
+	merits := make([]api.ChainMerit, 0)
+	return merits, client.getJSON(ctx, addressPath("/merits", address), &merits)
+}
+
+// Balances returns the fees credited to every producer address, only to address if it is not empty
+func (client *Client) Balances(ctx context.Context, address string) (map[string]api.MinerBalanceData, error) {
+	balances := make(map[string]api.MinerBalanceData)
+	return balances, client.getJSON(ctx, addressPath("/balance", address), &balances)
+}
+
+// NodeInfo returns the identity and chain height of the node that answers
+func (client *Client) NodeInfo(ctx context.Context) (api.NodeInfoData, error) {
+	var info api.NodeInfoData
+	return info, client.getJSON(ctx, "/node/info", &info)
+}
+
+// Peers returns the reputation of the peers of the node that answers
+func (client *Client) Peers(ctx context.Context) ([]api.PeerReputation, error) {
+	peers := make([]api.PeerReputation, 0)
+	return peers, client.getJSON(ctx, "/peers", &peers)
+}
+
+// Transaction looks a transaction up in the mempool and the chain, ErrNotFound if the node does not know it
+func (client *Client) Transaction(ctx context.Context, hash string) (tx.Transaction, error) {
+	body, err := client.get(ctx, "/transaction/"+url.PathEscape(hash))
+	if err != nil {
+		return tx.Transaction{}, err
+	}
+	var t tx.Transaction
+	if err := t.DecodeFromJSON(string(body)); err != nil {
+		return tx.Transaction{}, fmt.Errorf("malformed transaction %v: %v", hash, err)
+	}
+	return t, nil
+}
+
+// Block returns the block with the height and hash, ErrNotFound if the node does not have it
+func (client *Client) Block(ctx context.Context, height int32, hash string) (api.Block, error) {
+	var block api.Block
+	return block, client.getJSON(ctx, fmt.Sprintf("/block/%d/%v", height, url.PathEscape(hash)), &block)
+}
+
+// Show returns the peer list and the blocks of the node as text
+func (client *Client) Show(ctx context.Context) (string, error) {
+	body, err := client.get(ctx, "/show")
+	return string(body), err
+}
+
+// Canonical returns the chains of the node from the tips to the genesis as text
+func (client *Client) Canonical(ctx context.Context) (string, error) {
+	body, err := client.get(ctx, "/canonical")
+	return string(body), err
+}
+
+// Submit posts a signed transaction, a refusal is returned as *RejectedError. A node that already queued the
+// transaction, e.g. because an earlier attempt reached it before failing, counts as success
+func (client *Client) Submit(ctx context.Context, t tx.Transaction) error {
+	tjson, err := t.EncodeToJSON()
+	if err != nil {
+		return err
+	}
+	status, body, err := client.do(ctx, "POST", "/transaction", []byte(tjson))
+	if err != nil {
+		return err
+	}
+	reason := strings.TrimSpace(string(body))
+	if status == http.StatusConflict && reason == "duplicate" {
+		return nil
+	}
+	if status != http.StatusOK {
+		return &RejectedError{Status: status, Reason: reason}
+	}
+	return nil
+}
+
+// Status returns whether a transaction is pending, mined or finalized, ErrNotFound if the node does not know it
+func (client *Client) Status(ctx context.Context, hash string) (TransactionStatus, error) {
+	status := TransactionStatus{Hash: hash}
+	transactions, err := client.Transactions(ctx, "")
+	if err != nil {
+		return status, err
+	}
+	if t, ok := FindTransaction(transactions, hash); ok {
+		status.State = STATE_MINED
+		if t.Finalized {
+			status.State = STATE_FINALIZED
+		}
+		status.Finality = t.Finality
+		return status, nil
+	}
+	if _, err := client.Transaction(ctx, hash); err != nil {
+		return status, err
+	}
+	status.State = STATE_PENDING
+	return status, nil
+}
+
+// FindTransaction returns the transaction of the canonical chain with the hash
+func FindTransaction(transactions []api.ChainTransaction, hash string) (api.ChainTransaction, bool) {
+	for _, t := range transactions {
+		if t.Hash == hash {
+			return t, true
+		}
+	}
+	return api.ChainTransaction{}, false
+}
+
+// FindMerit returns the application transaction publishing the merit with the hash
+func FindMerit(transactions []api.ChainTransaction, meritHash string) (api.ChainTransaction, bool) {
+	for _, t := range transactions {
+		if t.TXType != tx.TYPE_APPLICATION {
+			continue
+		}
+		payload, err := t.DecodePayload()
+		if err == nil && payload.(*tx.ApplicationPayload).Hash == meritHash {
+			return t, true
+		}
+	}
+	return api.ChainTransaction{}, false
+}
@@ -1,42 +0,0 @@
-package main
-
-import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/x509"
-	"encoding/pem"
-	"fmt"
-	"os"
-)
-
-func main() {
-	if len(os.Args) != 2 {
-		fmt.Println("Usage: go run keygen.go <output pem file>")
-		return
-	}
-	outputfile := os.Args[1]
-
-	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	if err != nil {
-		panic(err)
-	}
-
-	x509Encoded, _ := x509.MarshalECPrivateKey(privateKey)
-	pemEncoded := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: x509Encoded})
-
-	f, err := os.Create(outputfile)
-	if err != nil {
-		panic(err)
-	}
-
-	l, err := f.WriteString(string(pemEncoded))
-	if err != nil {
-		fmt.Println(err)
-		f.Close()
-		return
-	}
-
-	fmt.Println(l, "bytes written successfully to "+outputfile)
-	f.Close()
-}
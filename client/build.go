@@ -0,0 +1,98 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+
+	"../models"
+	"../transaction"
+)
+
+var ErrTransactionType = errors.New("unexpected transaction type")
+
+// NewTransaction returns a transaction carrying payload to the hash to, signed by key
+func NewTransaction(key *ecdsa.PrivateKey, to string, fee float32, payload tx.Payload) (tx.Transaction, error) {
+	f, err := UnsignedTransaction(&key.PublicKey, to, fee, payload)
+	return sign(f, key, err)
+}
+
+// NewApplication returns the transaction publishing the merit of an application. The merit is published together
+// with the signature over the whole application, which lets an employer check the identity once the applicant
+// confirms an acceptance. The hash of the merit is the Hash of the ApplicationPayload
+func NewApplication(key *ecdsa.PrivateKey, application models.Application, fee float32) (tx.Transaction, error) {
+	return sign(UnsignedApplication(&key.PublicKey, application, fee), key, nil)
+}
+
+// NewAcceptance returns the transaction of an employer accepting the merit, the applicant encrypts the identity
+// with employerKey
+func NewAcceptance(key *ecdsa.PrivateKey, meritHash string, employerKey *rsa.PublicKey, fee float32) (tx.Transaction, error) {
+	f, err := UnsignedAcceptance(&key.PublicKey, meritHash, employerKey, fee)
+	return sign(f, key, err)
+}
+
+// NewConfirmation returns the transaction of an applicant confirming the acceptance, the identity is encrypted
+// with the key of the employer carried by the acceptance
+func NewConfirmation(key *ecdsa.PrivateKey, acceptance tx.Transaction, identity models.Identity, fee float32) (tx.Transaction, error) {
+	f, err := UnsignedConfirmation(&key.PublicKey, acceptance, identity, fee)
+	return sign(f, key, err)
+}
+
+// sign returns the transaction of a file built without error, signed by key
+func sign(f TransactionFile, key *ecdsa.PrivateKey, err error) (tx.Transaction, error) {
+	if err != nil {
+		return tx.Transaction{}, err
+	}
+	if err := f.Sign(key); err != nil {
+		return tx.Transaction{}, err
+	}
+	return f.Transaction, nil
+}
+
+// DecryptIdentity returns the identity an applicant sent with the confirmation, key is the rsa key the merit was
+// accepted with
+func DecryptIdentity(key *rsa.PrivateKey, confirmation tx.Transaction) (models.Identity, error) {
+	var identity models.Identity
+	if confirmation.TXType != tx.TYPE_CONFIRMATION {
+		return identity, ErrTransactionType
+	}
+	payload, err := confirmation.DecodePayload()
+	if err != nil {
+		return identity, err
+	}
+	identityBytes, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, payload.(*tx.ConfirmationPayload).Ciphertext, []byte(""))
+	if err != nil {
+		return identity, err
+	}
+	return identity, json.Unmarshal(identityBytes, &identity)
+}
+
+// VerifyIdentity assembles the original application from the merit and a decrypted identity. ok is set if the
+// applicant signed the merit together with this identity
+func VerifyIdentity(merit tx.Transaction, identity models.Identity) (application models.TimestampedApplication, ok bool, err error) {
+	if merit.TXType != tx.TYPE_APPLICATION {
+		return application, false, ErrTransactionType
+	}
+	payload, err := merit.DecodePayload()
+	if err != nil {
+		return application, false, err
+	}
+	sm := payload.(*tx.ApplicationPayload).SignedMerit
+	application = models.TimestampedApplication{
+		Application: models.Application{Identity: identity, Merit: sm.Merit},
+		Timestamp:   sm.Timestamp,
+	}
+	applicationBytes, err := json.Marshal(application)
+	if err != nil {
+		return application, false, err
+	}
+	applicationHash := sha256.Sum256(applicationBytes)
+	applicantKey, err := tx.DecodeECDSAPublicKey(merit.From)
+	if err != nil {
+		return application, false, err
+	}
+	return application, ecdsa.Verify(applicantKey, applicationHash[:], sm.Signature.R, sm.Signature.S), nil
+}
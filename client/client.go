@@ -0,0 +1,192 @@
+// Package client is the Go client of the node api. A Client queries and submits transactions to a list of nodes,
+// a request that cannot be answered by one node is sent to the next and the whole list is retried with a backoff:
+//
+//	c := client.New("localhost:6686", "localhost:6687")
+//	info, err := c.NodeInfo(ctx)
+//	t, err := client.NewAcceptance(key, meritHash, employerKey, 0.1)
+//	err = c.Submit(ctx, t)
+//
+// The builders in this package sign every transaction type, the keys are never sent to a node.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of a new Client
+const (
+	DEFAULT_TIMEOUT = 10 * time.Second
+	DEFAULT_RETRIES = 2
+	DEFAULT_BACKOFF = 500 * time.Millisecond
+)
+
+var ErrNoNodes = errors.New("no node address given")
+var ErrNotFound = errors.New("not found")
+
+// RejectedError is returned if a node refused a request, e.g. an invalid or conflicting transaction. Status is
+// the http status and Reason the explanation of the node
+type RejectedError struct {
+	Status int
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("refused by the node: %v", http.StatusText(e.Status))
+	}
+	return "refused by the node: " + e.Reason
+}
+
+// UnreachableError is returned if no node answered a request, Err is the failure of the last attempt
+type UnreachableError struct {
+	Err error
+}
+
+func (e *UnreachableError) Error() string {
+	return "no node answered: " + e.Err.Error()
+}
+
+func (e *UnreachableError) Unwrap() error {
+	return e.Err
+}
+
+// Client sends requests to the nodes in Addrs. The fields may be changed before the first request
+type Client struct {
+	Addrs []string
+	HTTP  *http.Client
+	// Retries is how often the whole list of nodes is tried again after every node failed
+	Retries int
+	Backoff time.Duration
+
+	// preferred is the node that answered the last request, it is tried first
+	preferred int
+	mux       sync.Mutex
+}
+
+// New returns a Client of the nodes at addrs, an address without a scheme is reached over http
+func New(addrs ...string) *Client {
+	client := &Client{
+		HTTP:    &http.Client{Timeout: DEFAULT_TIMEOUT},
+		Retries: DEFAULT_RETRIES,
+		Backoff: DEFAULT_BACKOFF,
+	}
+	for _, addr := range addrs {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if !strings.Contains(addr, "://") {
+			addr = "http://" + addr
+		}
+		client.Addrs = append(client.Addrs, strings.TrimRight(addr, "/"))
+	}
+	return client
+}
+
+// do sends the request to the nodes until one answers and returns the status and body of the answer. A node
+// that cannot be reached or fails with a server error is skipped
+func (client *Client) do(ctx context.Context, method string, path string, body []byte) (status int, answer []byte, err error) {
+	err = client.try(ctx, func(addr string) error {
+		var err error
+		status, answer, err = client.send(ctx, method, addr+path, body)
+		if err == nil && status >= 500 {
+			err = fmt.Errorf("%v answered %v: %v", addr, status, strings.TrimSpace(string(answer)))
+		}
+		return err
+	})
+	return status, answer, err
+}
+
+// try calls attempt with the nodes until it succeeds, starting with the node that answered last. The whole list is
+// tried Retries more times with a growing backoff, the error of the last attempt is returned if every node failed
+func (client *Client) try(ctx context.Context, attempt func(addr string) error) error {
+	if len(client.Addrs) == 0 {
+		return ErrNoNodes
+	}
+	client.mux.Lock()
+	first := client.preferred
+	client.mux.Unlock()
+
+	var lastErr error
+	for retry := 0; retry <= client.Retries; retry++ {
+		if retry > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(client.Backoff * time.Duration(retry)):
+			}
+		}
+		for i := range client.Addrs {
+			n := (first + i) % len(client.Addrs)
+			err := attempt(client.Addrs[n])
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			client.mux.Lock()
+			client.preferred = n
+			client.mux.Unlock()
+			return nil
+		}
+	}
+	return &UnreachableError{Err: lastErr}
+}
+
+func (client *Client) send(ctx context.Context, method string, url string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := client.HTTP.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+	answer, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, answer, nil
+}
+
+// get sends a query and returns the body of the answer, ErrNotFound if the node has no content for it
+func (client *Client) get(ctx context.Context, path string) ([]byte, error) {
+	status, body, err := client.do(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case status == http.StatusNoContent:
+		return nil, ErrNotFound
+	case status >= 400:
+		return nil, &RejectedError{Status: status, Reason: strings.TrimSpace(string(body))}
+	}
+	return body, nil
+}
+
+// getJSON decodes the json answer to a query into v
+func (client *Client) getJSON(ctx context.Context, path string, v interface{}) error {
+	body, err := client.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("malformed answer to %v: %v", path, err)
+	}
+	return nil
+}
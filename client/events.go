@@ -0,0 +1,106 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"../api"
+)
+
+// MAX_EVENT_SIZE is the largest event read from a stream
+const MAX_EVENT_SIZE = 1 << 20
+
+var ErrStreamEnded = errors.New("event stream ended by the node")
+
+// EventFilter selects the events streamed by Events, empty fields select everything. Address or Key select the
+// transactions of an account and those referring to its merits and acceptances, Merit the transactions of a merit
+// and Type the transactions of a type
+type EventFilter struct {
+	Address string
+	Key     string
+	Merit   string
+	Type    string
+}
+
+func (filter EventFilter) query() string {
+	query := url.Values{}
+	for name, value := range map[string]string{"address": filter.Address, "key": filter.Key, "merit": filter.Merit, "type": filter.Type} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+// Events subscribes to the events of the transactions matching filter and calls handle with every event. It
+// returns when ctx is done, handle fails or the stream ends, ErrStreamEnded if the node ended it. The stream is
+// opened on the first node that answers, the caller subscribes again to move to another node
+func (client *Client) Events(ctx context.Context, filter EventFilter, handle func(api.Event) error) error {
+	//The stream is ended by ctx, the timeout of the client would cut it
+	stream := &http.Client{Transport: client.HTTP.Transport}
+	var res *http.Response
+	err := client.try(ctx, func(addr string) error {
+		req, err := http.NewRequestWithContext(ctx, "GET", addr+"/events"+filter.query(), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "text/event-stream")
+		res, err = stream.Do(req)
+		if err == nil && res.StatusCode >= 500 {
+			res.Body.Close()
+			err = fmt.Errorf("%v answered %v", addr, res.StatusCode)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		reason, _ := ioutil.ReadAll(res.Body)
+		return &RejectedError{Status: res.StatusCode, Reason: strings.TrimSpace(string(reason))}
+	}
+
+	//Every event is a block of "field: value" lines ended by an empty line, only the data is needed because the
+	//json names the event too. Lines starting with ':' are comments keeping the connection alive
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 0, 4096), MAX_EVENT_SIZE)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			if strings.HasPrefix(line, "data:") {
+				data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			}
+			continue
+		}
+		if data.Len() == 0 {
+			continue
+		}
+		var e api.Event
+		if err := json.Unmarshal([]byte(data.String()), &e); err != nil {
+			return fmt.Errorf("malformed event: %v", err)
+		}
+		data.Reset()
+		if err := handle(e); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return ErrStreamEnded
+}
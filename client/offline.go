@@ -0,0 +1,202 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"../models"
+	"../transaction"
+)
+
+// FILE_VERSION is the version of the transaction files written by this package
+const FILE_VERSION = 1
+
+var ErrUnsigned = errors.New("transaction is not signed")
+var ErrHash = errors.New("hash does not match the transaction")
+var ErrSignature = errors.New("signature does not match the sender")
+var ErrKey = errors.New("key is not the key of the sender")
+
+// TransactionFile carries a transaction from the machine that builds it to the machine holding the key and from
+// there to the machine that broadcasts it, so that the key never has to be on a networked machine. The merit of an
+// application is signed together with the identity, so an unsigned application carries the Application and gets
+// its payload when it is signed. The identity is dropped from the file once it is signed
+type TransactionFile struct {
+	Version     int                 `json:"version"`
+	Transaction tx.Transaction      `json:"transaction"`
+	Application *models.Application `json:"application,omitempty"`
+}
+
+// UnsignedTransaction returns the file of a transaction carrying payload to the hash to, sent by the owner of from
+func UnsignedTransaction(from *ecdsa.PublicKey, to string, fee float32, payload tx.Payload) (TransactionFile, error) {
+	f := TransactionFile{Version: FILE_VERSION, Transaction: unsigned(from, to, fee)}
+	return f, f.Transaction.SetPayload(payload)
+}
+
+// UnsignedApplication returns the file of a transaction publishing the merit of the application
+func UnsignedApplication(from *ecdsa.PublicKey, application models.Application, fee float32) TransactionFile {
+	t := unsigned(from, "", fee)
+	t.TXType = tx.TYPE_APPLICATION
+	return TransactionFile{Version: FILE_VERSION, Transaction: t, Application: &application}
+}
+
+// UnsignedAcceptance returns the file of a transaction accepting the merit, see NewAcceptance
+func UnsignedAcceptance(from *ecdsa.PublicKey, meritHash string, employerKey *rsa.PublicKey, fee float32) (TransactionFile, error) {
+	return UnsignedTransaction(from, meritHash, fee, &tx.AcceptancePayload{EmployerKey: employerKey})
+}
+
+// UnsignedConfirmation returns the file of a transaction confirming the acceptance, see NewConfirmation. Only the
+// public key of the employer is needed, so the identity is encrypted when the file is built
+func UnsignedConfirmation(from *ecdsa.PublicKey, acceptance tx.Transaction, identity models.Identity, fee float32) (TransactionFile, error) {
+	if acceptance.TXType != tx.TYPE_ACCEPTANCE {
+		return TransactionFile{}, ErrTransactionType
+	}
+	payload, err := acceptance.DecodePayload()
+	if err != nil {
+		return TransactionFile{}, err
+	}
+	identityBytes, err := json.Marshal(identity)
+	if err != nil {
+		return TransactionFile{}, err
+	}
+	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, payload.(*tx.AcceptancePayload).EmployerKey, identityBytes, []byte(""))
+	if err != nil {
+		return TransactionFile{}, err
+	}
+	return UnsignedTransaction(from, acceptance.Hash, fee, &tx.ConfirmationPayload{Ciphertext: ciphertext})
+}
+
+func unsigned(from *ecdsa.PublicKey, to string, fee float32) tx.Transaction {
+	return tx.Transaction{
+		Version:   tx.CURRENT_VERSION,
+		From:      tx.EncodeECDSAPublicKey(from),
+		To:        to,
+		TXFee:     fee,
+		Timestamp: time.Now().UnixNano() / 1000000,
+	}
+}
+
+// Sign signs the transaction with the key of the sender, ErrKey if key is not the key the file was built for
+func (f *TransactionFile) Sign(key *ecdsa.PrivateKey) error {
+	if f.Transaction.From != tx.EncodeECDSAPublicKey(&key.PublicKey) {
+		return ErrKey
+	}
+	if f.Application != nil {
+		signedMerit, err := signMerit(key, *f.Application, f.Transaction.Timestamp)
+		if err != nil {
+			return err
+		}
+		if err := f.Transaction.SetPayload(&tx.ApplicationPayload{SignedMerit: signedMerit}); err != nil {
+			return err
+		}
+		f.Application = nil
+	}
+	if _, err := f.Transaction.DecodePayload(); err != nil {
+		return err
+	}
+	f.Transaction.Hash = f.Transaction.GenHash()
+	f.Transaction.Sign(key)
+	return nil
+}
+
+// signMerit signs the whole application and returns the merit with the signature
+func signMerit(key *ecdsa.PrivateKey, application models.Application, timestamp int64) (models.SignedMerit, error) {
+	fullApplication := models.TimestampedApplication{Application: application, Timestamp: timestamp}
+	fullApplicationBytes, err := json.Marshal(fullApplication)
+	if err != nil {
+		return models.SignedMerit{}, err
+	}
+	fullApplicationHash := sha256.Sum256(fullApplicationBytes)
+	r, s, err := ecdsa.Sign(rand.Reader, key, fullApplicationHash[:])
+	if err != nil {
+		return models.SignedMerit{}, err
+	}
+	return models.SignedMerit{
+		Merit:     application.Merit,
+		Timestamp: timestamp,
+		Hash:      hex.EncodeToString(fullApplicationHash[:]),
+		Signature: models.ECDSASignature{R: r, S: s},
+	}, nil
+}
+
+// Signed tells whether the transaction of the file has been signed
+func (f *TransactionFile) Signed() bool {
+	return f.Application == nil && f.Transaction.Signature.R != nil && f.Transaction.Signature.S != nil
+}
+
+// Check returns nil if the transaction can be broadcast and ErrUnsigned if it is well formed but still has to be
+// signed. Any other error tells why the transaction would be refused
+func (f *TransactionFile) Check() error {
+	t := f.Transaction
+	if t.Version != tx.CURRENT_VERSION {
+		return fmt.Errorf("unsupported transaction version %d", t.Version)
+	}
+	if _, err := tx.DecodeECDSAPublicKey(t.From); err != nil {
+		return fmt.Errorf("invalid sender: %v", err)
+	}
+	if f.Application != nil {
+		if t.TXType != tx.TYPE_APPLICATION {
+			return ErrTransactionType
+		}
+		return ErrUnsigned
+	}
+	if _, err := t.DecodePayload(); err != nil {
+		return fmt.Errorf("invalid payload: %v", err)
+	}
+	if !f.Signed() {
+		return ErrUnsigned
+	}
+	if t.Hash != t.GenHash() {
+		return ErrHash
+	}
+	if !t.Verify() {
+		return ErrSignature
+	}
+	return nil
+}
+
+// ReadTransactionFile reads a transaction file, a file holding a bare transaction as answered by the node is read
+// as a signed transaction file
+func ReadTransactionFile(path string) (TransactionFile, error) {
+	var f TransactionFile
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+	var probe struct {
+		Transaction json.RawMessage `json:"transaction"`
+	}
+	if err := json.Unmarshal(content, &probe); err != nil {
+		return f, fmt.Errorf("malformed transaction file %v: %v", path, err)
+	}
+	if probe.Transaction == nil {
+		f.Version = FILE_VERSION
+		if err := f.Transaction.DecodeFromJSON(string(content)); err != nil {
+			return f, fmt.Errorf("malformed transaction file %v: %v", path, err)
+		}
+		return f, nil
+	}
+	if err := json.Unmarshal(content, &f); err != nil {
+		return f, fmt.Errorf("malformed transaction file %v: %v", path, err)
+	}
+	if f.Version != FILE_VERSION {
+		return f, fmt.Errorf("unsupported transaction file version %d", f.Version)
+	}
+	return f, nil
+}
+
+// WriteTransactionFile writes the file readable only by the owner, an unsigned application holds the identity
+func WriteTransactionFile(path string, f TransactionFile) error {
+	content, err := json.MarshalIndent(f, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(content, '\n'), 0600)
+}
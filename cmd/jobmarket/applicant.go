@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+
+	"../../api"
+	"../../client"
+	"../../models"
+	"../../transaction"
+)
+
+// readApplication reads the identity and merit of an applicant from a json file
+func readApplication(path string) (models.Application, error) {
+	var application models.Application
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return application, err
+	}
+	if err := json.Unmarshal(content, &application); err != nil {
+		return application, exit(EXIT_USAGE, "malformed application %v: %v", path, err)
+	}
+	return application, nil
+}
+
+// AppliedData is the result of apply
+type AppliedData struct {
+	Transaction string `json:"transaction"`
+	Merit       string `json:"merit"`
+	Address     string `json:"address"`
+}
+
+func runApply(c *cli, args []string) error {
+	fs := c.newFlagSet()
+	s := newSender(fs, "applicant")
+	fee := fs.Float64("fee", 0.1, "transaction fee")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	application, err := readApplication(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	from, err := c.publicKey(s)
+	if err != nil {
+		return err
+	}
+
+	t, err := c.send(s, client.UnsignedApplication(from, application, float32(*fee)))
+	if t == nil {
+		return err
+	}
+	payload, err := t.DecodePayload()
+	if err != nil {
+		return err
+	}
+	applied := AppliedData{Transaction: t.Hash, Merit: payload.(*tx.ApplicationPayload).Hash, Address: t.FromAddress()}
+	return c.out.fields(applied, "Transaction", applied.Transaction, "Merit", applied.Merit, "Address", applied.Address)
+}
+
+// MeritView is a merit of an applicant with the acceptances of employers
+type MeritView struct {
+	api.ChainMerit
+	Acceptances []AcceptanceView `json:"acceptances"`
+}
+
+// AcceptanceView is an acceptance of a merit, Confirmed is set once the applicant sent the identity
+type AcceptanceView struct {
+	Hash      string `json:"hash"`
+	Employer  string `json:"employer"`
+	Confirmed bool   `json:"confirmed"`
+	api.Finality
+}
+
+func runView(c *cli, args []string) error {
+	fs := c.newFlagSet()
+	keyName := fs.String("key", "", "name of the ecdsa key of the applicant")
+	address := fs.String("address", "", "address of the applicant, instead of -key")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if (*keyName == "") == (*address == "") {
+		fs.Usage()
+		return exit(EXIT_USAGE, "either -key or -address is required")
+	}
+	if *keyName != "" {
+		ks, err := c.openKeystore()
+		if err != nil {
+			return err
+		}
+		//Only the address is needed, so the key stays encrypted
+		info, err := ks.Info(*keyName)
+		if err != nil {
+			return err
+		}
+		*address = info.Address
+	}
+	if err := tx.ValidateAddress(*address); err != nil {
+		return exit(EXIT_USAGE, "invalid address: %v", err)
+	}
+
+	merits, err := c.node.Merits(c.ctx, *address)
+	if err != nil {
+		return err
+	}
+	transactions, err := c.node.Transactions(c.ctx, "")
+	if err != nil {
+		return err
+	}
+	views := make([]MeritView, 0, len(merits))
+	rows := make([][]string, 0)
+	for _, m := range merits {
+		view := MeritView{ChainMerit: m, Acceptances: make([]AcceptanceView, 0)}
+		for _, t := range transactions {
+			if t.TXType != tx.TYPE_ACCEPTANCE || t.To != m.Hash {
+				continue
+			}
+			acceptance := AcceptanceView{Hash: t.Hash, Employer: t.FromAddress, Finality: t.Finality}
+			for _, confirmation := range transactions {
+				if confirmation.TXType == tx.TYPE_CONFIRMATION && confirmation.To == t.Hash {
+					acceptance.Confirmed = true
+				}
+			}
+			view.Acceptances = append(view.Acceptances, acceptance)
+			rows = append(rows, []string{m.Hash, describe(m.Finality), t.Hash, acceptance.Employer, yesNo(acceptance.Confirmed)})
+		}
+		if len(view.Acceptances) == 0 {
+			rows = append(rows, []string{m.Hash, describe(m.Finality), "-", "-", "-"})
+		}
+		views = append(views, view)
+	}
+	return c.out.print(views, []string{"MERIT", "STATE", "ACCEPTANCE", "EMPLOYER", "CONFIRMED"}, rows)
+}
+
+// ConfirmedData is the result of confirm
+type ConfirmedData struct {
+	Transaction string `json:"transaction"`
+	Acceptance  string `json:"acceptance"`
+	Employer    string `json:"employer"`
+}
+
+func runConfirm(c *cli, args []string) error {
+	fs := c.newFlagSet()
+	s := newSender(fs, "applicant")
+	fee := fs.Float64("fee", 0.1, "transaction fee")
+	if err := parse(fs, args, 2); err != nil {
+		return err
+	}
+	acceptanceHash := fs.Arg(0)
+	application, err := readApplication(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	from, err := c.publicKey(s)
+	if err != nil {
+		return err
+	}
+
+	transactions, err := c.node.Transactions(c.ctx, "")
+	if err != nil {
+		return err
+	}
+	acceptance, ok := client.FindTransaction(transactions, acceptanceHash)
+	if !ok || acceptance.TXType != tx.TYPE_ACCEPTANCE {
+		return exit(EXIT_NOT_FOUND, "acceptance %v does not exist", acceptanceHash)
+	}
+	//Only the applicant who published the merit can confirm its acceptance
+	merit, ok := client.FindMerit(transactions, acceptance.To)
+	if !ok || merit.FromAddress != tx.Address(from) {
+		return errors.New("the accepted merit was not published by " + tx.Address(from))
+	}
+	f, err := client.UnsignedConfirmation(from, acceptance.Transaction, application.Identity, float32(*fee))
+	if err != nil {
+		return err
+	}
+	t, err := c.send(s, f)
+	if t == nil {
+		return err
+	}
+	confirmed := ConfirmedData{Transaction: t.Hash, Acceptance: acceptanceHash, Employer: acceptance.FromAddress}
+	return c.out.fields(confirmed, "Transaction", confirmed.Transaction, "Acceptance", confirmed.Acceptance, "Employer", confirmed.Employer)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"../../api"
+	"../../client"
+)
+
+// exitCode returns the exit code of an error of the node client
+func exitCode(err error) int {
+	var rejected *client.RejectedError
+	var unreachable *client.UnreachableError
+	switch {
+	case errors.As(err, &rejected):
+		return EXIT_REJECTED
+	case errors.As(err, &unreachable), errors.Is(err, client.ErrNoNodes):
+		return EXIT_UNREACHABLE
+	case errors.Is(err, client.ErrNotFound):
+		return EXIT_NOT_FOUND
+	}
+	return EXIT_ERROR
+}
+
+// describe returns the position of a mined transaction for table output
+func describe(f api.Finality) string {
+	if f.Finalized {
+		return fmt.Sprintf("finalized at height %d", f.BlockHeight)
+	}
+	return fmt.Sprintf("%d confirmations", f.Confirmations)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+
+	"../../api"
+	"../../client"
+	"../../models"
+	"../../transaction"
+)
+
+// AcceptedData is the result of accept
+type AcceptedData struct {
+	Transaction string `json:"transaction"`
+	Merit       string `json:"merit"`
+	Applicant   string `json:"applicant"`
+}
+
+func runAccept(c *cli, args []string) error {
+	fs := c.newFlagSet()
+	s := newSender(fs, "employer")
+	rsaName := fs.String("rsa", "", "name of the rsa key the applicant encrypts the identity with")
+	fee := fs.Float64("fee", 0.1, "transaction fee")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	meritHash := fs.Arg(0)
+	from, err := c.publicKey(s)
+	if err != nil {
+		return err
+	}
+	ks, err := c.openKeystore()
+	if err != nil {
+		return err
+	}
+	//Only the public key is published, so the rsa key stays encrypted
+	rsaInfo, err := ks.Info(*rsaName)
+	if err != nil {
+		return err
+	}
+	employerKey, err := tx.DecodeRSAPublicKey(rsaInfo.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	transactions, err := c.node.Transactions(c.ctx, "")
+	if err != nil {
+		return err
+	}
+	merit, ok := client.FindMerit(transactions, meritHash)
+	if !ok {
+		return exit(EXIT_NOT_FOUND, "merit %v does not exist", meritHash)
+	}
+
+	f, err := client.UnsignedAcceptance(from, meritHash, employerKey, float32(*fee))
+	if err != nil {
+		return err
+	}
+	t, err := c.send(s, f)
+	if t == nil {
+		return err
+	}
+	accepted := AcceptedData{Transaction: t.Hash, Merit: meritHash, Applicant: merit.FromAddress}
+	return c.out.fields(accepted, "Transaction", accepted.Transaction, "Merit", accepted.Merit, "Applicant", accepted.Applicant)
+}
+
+// ConfirmationData is the identity an applicant confirmed an acceptance with, Verified is set if the identity is
+// the one the applicant signed together with the merit
+type ConfirmationData struct {
+	Merit        string                        `json:"merit"`
+	Acceptance   string                        `json:"acceptance"`
+	Confirmation string                        `json:"confirmation"`
+	Applicant    string                        `json:"applicant"`
+	Verified     bool                          `json:"verified"`
+	Application  models.TimestampedApplication `json:"application"`
+}
+
+func runConfirmations(c *cli, args []string) error {
+	fs := c.newFlagSet()
+	rsaName := fs.String("rsa", "", "name of the rsa key the merit was accepted with")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	meritHash := fs.Arg(0)
+	ks, err := c.openKeystore()
+	if err != nil {
+		return err
+	}
+	rsaInfo, err := ks.Info(*rsaName)
+	if err != nil {
+		return err
+	}
+
+	transactions, err := c.node.Transactions(c.ctx, "")
+	if err != nil {
+		return err
+	}
+	merit, ok := client.FindMerit(transactions, meritHash)
+	if !ok {
+		return exit(EXIT_NOT_FOUND, "merit %v does not exist", meritHash)
+	}
+	//The acceptance of this employer is the one carrying its rsa key
+	var acceptanceHash string
+	for _, t := range transactions {
+		if t.TXType != tx.TYPE_ACCEPTANCE || t.To != meritHash {
+			continue
+		}
+		payload, err := t.DecodePayload()
+		if err == nil && tx.EncodeRSAPublicKey(payload.(*tx.AcceptancePayload).EmployerKey) == rsaInfo.PublicKey {
+			acceptanceHash = t.Hash
+			break
+		}
+	}
+	if acceptanceHash == "" {
+		return exit(EXIT_NOT_FOUND, "merit %v has not been accepted with %v", meritHash, *rsaName)
+	}
+	var confirmation *api.ChainTransaction
+	for i, t := range transactions {
+		if t.TXType == tx.TYPE_CONFIRMATION && t.To == acceptanceHash && t.FromAddress == merit.FromAddress {
+			confirmation = &transactions[i]
+			break
+		}
+	}
+	if confirmation == nil {
+		return exit(EXIT_NOT_FOUND, "the applicant has not yet confirmed acceptance %v", acceptanceHash)
+	}
+
+	rsaKey, err := ks.UnlockRSA(*rsaName)
+	if err != nil {
+		return err
+	}
+	identity, err := client.DecryptIdentity(rsaKey, confirmation.Transaction)
+	if err != nil {
+		return err
+	}
+	//The signature of the original application proves that the applicant published the merit with this identity
+	application, verified, err := client.VerifyIdentity(merit.Transaction, identity)
+	if err != nil {
+		return err
+	}
+	result := ConfirmationData{
+		Merit:        meritHash,
+		Acceptance:   acceptanceHash,
+		Confirmation: confirmation.Hash,
+		Applicant:    merit.FromAddress,
+		Verified:     verified,
+		Application:  application,
+	}
+
+	err = c.out.fields(result,
+		"Merit", result.Merit,
+		"Applicant", result.Applicant,
+		"Name", identity.Name,
+		"Email", identity.Email,
+		"Postal address", identity.Address,
+		"Verified", yesNo(result.Verified))
+	if err == nil && !result.Verified {
+		err = errors.New("signature verification failed, the identity is not the one signed with the merit")
+	}
+	return err
+}
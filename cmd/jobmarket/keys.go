@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+
+	"../../keystore"
+)
+
+func runKeys(c *cli, args []string) error {
+	if len(args) == 0 {
+		c.newFlagSet().Usage()
+		return exit(EXIT_USAGE, "missing keys command")
+	}
+	ks, err := c.openKeystore()
+	if err != nil {
+		return err
+	}
+	fs := c.newFlagSet()
+	switch args[0] {
+	case "list":
+		if err := parse(fs, args[1:], 0); err != nil {
+			return err
+		}
+		keys, err := ks.List()
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(keys))
+		for _, k := range keys {
+			rows = append(rows, []string{k.Name, k.Type, k.Address})
+		}
+		return c.out.print(keys, []string{"NAME", "TYPE", "ADDRESS"}, rows)
+	case "generate":
+		keyType := fs.String("type", keystore.KEY_ECDSA, "type of the key, ecdsa to sign transactions or rsa to receive identities")
+		if err := parse(fs, args[1:], 1); err != nil {
+			return err
+		}
+		passphrase, err := keystore.ReadNewPassphrase()
+		if err != nil {
+			return err
+		}
+		info, err := ks.Generate(fs.Arg(0), *keyType, passphrase)
+		if err != nil {
+			return err
+		}
+		return c.out.fields(info, "Name", info.Name, "Type", info.Type, "Address", info.Address)
+	case "import":
+		if err := parse(fs, args[1:], 2); err != nil {
+			return err
+		}
+		pemBytes, err := ioutil.ReadFile(fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		passphrase, err := keystore.ReadNewPassphrase()
+		if err != nil {
+			return err
+		}
+		info, err := ks.Import(fs.Arg(0), pemBytes, passphrase)
+		if err != nil {
+			return err
+		}
+		return c.out.fields(info, "Name", info.Name, "Type", info.Type, "Address", info.Address)
+	case "export":
+		if err := parse(fs, args[1:], 2); err != nil {
+			return err
+		}
+		passphrase, err := keystore.ReadPassphrase("Passphrase for " + fs.Arg(0) + ": ")
+		if err != nil {
+			return err
+		}
+		pemBytes, err := ks.Export(fs.Arg(0), passphrase)
+		if err != nil {
+			return err
+		}
+		//The exported key is not encrypted, it must not overwrite anything or be readable by others
+		f, err := os.OpenFile(fs.Arg(1), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(pemBytes)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+	c.newFlagSet().Usage()
+	return exit(EXIT_USAGE, "unknown keys command %q", args[0])
+}
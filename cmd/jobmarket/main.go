@@ -0,0 +1,185 @@
+// Command jobmarket is the client of the job market network. Applicants publish their merits and confirm
+// acceptances with their encrypted identity, employers accept merits and decrypt the identity of applicants.
+// All keys are read from an encrypted keystore:
+//
+//	jobmarket keys generate alice
+//	jobmarket -node localhost:6686 apply -key alice application.json
+//	jobmarket -output json view -key alice
+//
+// The key can be kept on a machine without network, the transaction is then built with the public key, signed
+// offline and broadcast later:
+//
+//	jobmarket accept -from <public key> -rsa hiring -unsigned accept.json <merit hash>
+//	jobmarket sign -key bob accept.json accept.signed.json
+//	jobmarket broadcast accept.signed.json
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"../../client"
+	"../../keystore"
+)
+
+// Exit codes, scripts can tell a refused transaction from an unreachable node
+const (
+	EXIT_OK          = 0
+	EXIT_ERROR       = 1 // any other error, e.g. a wrong passphrase or an unreadable file
+	EXIT_USAGE       = 2 // unknown command or wrong arguments
+	EXIT_NOT_FOUND   = 3 // the merit, acceptance, confirmation or transaction does not exist (yet)
+	EXIT_REJECTED    = 4 // the node refused the transaction or the query
+	EXIT_UNREACHABLE = 5 // the node could not be reached or answered with an error
+)
+
+// exitError is returned by commands that end with a specific exit code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func exit(code int, format string, args ...interface{}) error {
+	return &exitError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// cli holds the global flags shared by all commands and the command that runs
+type cli struct {
+	ctx      context.Context
+	node     *client.Client
+	keystore string
+	out      *printer
+	command  command
+}
+
+func (c *cli) openKeystore() (*keystore.Keystore, error) {
+	return keystore.Open(c.keystore)
+}
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(c *cli, args []string) error
+}
+
+var commands = []command{
+	{"keys", "list | generate [-type ecdsa|rsa] <name> | import <name> <pem> | export <name> <pem>", "manage the keys of the keystore", runKeys},
+	{"apply", "-key <name> [-fee <fee>] [-unsigned <file>] <application json>", "publish the merit of an application", runApply},
+	{"view", "-key <name> | -address <address>", "show the merits of an applicant and who accepted them", runView},
+	{"accept", "-key <name> -rsa <name> [-fee <fee>] [-unsigned <file>] <merit hash>", "accept a merit as an employer", runAccept},
+	{"confirm", "-key <name> [-fee <fee>] [-unsigned <file>] <acceptance hash> <application json>", "send the encrypted identity to an employer", runConfirm},
+	{"confirmations", "-rsa <name> <merit hash>", "decrypt and verify the identity confirmed for an accepted merit", runConfirmations},
+	{"sign", "-key <name> <unsigned file> <signed file>", "sign a transaction file, needs no node", runSign},
+	{"broadcast", "<signed file>", "send a signed transaction file to the node", runBroadcast},
+	{"verify", "<file>", "check the hash and signature of a transaction file", runVerify},
+	{"status", "[<transaction hash>]", "show the node or the state of a transaction", runStatus},
+	{"watch", "[-key <name> | -address <address>] [-merit <hash>] [-type <type>]", "print transactions as they are queued, mined or reorged", runWatch},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: jobmarket [flags] <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-14v %v\n  %-14v   %v\n", cmd.name, cmd.summary, "", cmd.args)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	nodeAddr := flag.String("node", "localhost:6686", "address of the node to query and submit transactions to, a comma separated list is tried in order")
+	keystoreDir := flag.String("keystore", keystore.DefaultDir(), "directory of the encrypted keystore")
+	output := flag.String("output", "table", "output format, table or json")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout of requests to the node")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(EXIT_USAGE)
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", *output)
+		os.Exit(EXIT_USAGE)
+	}
+	node := client.New(strings.Split(*nodeAddr, ",")...)
+	node.HTTP.Timeout = *timeout
+	c := &cli{
+		ctx:      context.Background(),
+		node:     node,
+		keystore: *keystoreDir,
+		out:      &printer{json: *output == "json", w: os.Stdout},
+	}
+
+	name := flag.Arg(0)
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage()
+		os.Exit(EXIT_USAGE)
+	}
+
+	c.command = cmd
+	err := cmd.run(c, flag.Args()[1:])
+	if err == nil {
+		os.Exit(EXIT_OK)
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(EXIT_USAGE)
+	}
+	fmt.Fprintln(os.Stderr, "jobmarket "+name+": "+err.Error())
+	var e *exitError
+	if errors.As(err, &e) {
+		os.Exit(e.code)
+	}
+	os.Exit(exitCode(err))
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// newFlagSet returns the flags of the running command, parse errors are reported with the usage of the command
+func (c *cli) newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.command.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: jobmarket %v %v\n", c.command.name, c.command.args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses the flags of a command and checks the number of positional arguments
+func parse(fs *flag.FlagSet, args []string, nargs int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &exitError{code: EXIT_USAGE, err: err}
+	}
+	if fs.NArg() != nargs {
+		fs.Usage()
+		return exit(EXIT_USAGE, "expected %d arguments, got %d", nargs, fs.NArg())
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"../../client"
+	"../../keystore"
+	"../../transaction"
+)
+
+// sender holds the flags naming who sends a new transaction. With -unsigned the transaction is written to a file
+// to be signed on another machine, only the public key is needed then and it may be given with -from
+type sender struct {
+	key      *string
+	from     *string
+	unsigned *string
+}
+
+func newSender(fs *flag.FlagSet, role string) *sender {
+	return &sender{
+		key:      fs.String("key", "", "name of the ecdsa key of the "+role),
+		from:     fs.String("from", "", "public key of the "+role+" if the key is not in the keystore, only with -unsigned"),
+		unsigned: fs.String("unsigned", "", "write the unsigned transaction to this file instead of signing and sending it"),
+	}
+}
+
+// publicKey returns the public key the transaction is built for, the private key stays encrypted
+func (c *cli) publicKey(s *sender) (*ecdsa.PublicKey, error) {
+	switch {
+	case *s.unsigned == "" && *s.from != "":
+		return nil, exit(EXIT_USAGE, "-from requires -unsigned, the transaction is signed with -key")
+	case (*s.key == "") == (*s.from == ""):
+		return nil, exit(EXIT_USAGE, "either -key or -from is required")
+	case *s.from != "":
+		from, err := tx.DecodeECDSAPublicKey(*s.from)
+		if err != nil {
+			return nil, exit(EXIT_USAGE, "invalid public key: %v", err)
+		}
+		return from, nil
+	}
+	ks, err := c.openKeystore()
+	if err != nil {
+		return nil, err
+	}
+	info, err := ks.Info(*s.key)
+	if err != nil {
+		return nil, err
+	}
+	if info.Type != keystore.KEY_ECDSA {
+		return nil, keystore.ErrKeyType
+	}
+	return tx.DecodeECDSAPublicKey(info.PublicKey)
+}
+
+// send signs and submits the transaction of f, or writes it to the -unsigned file. The transaction is nil if it
+// was written to the file, the file has been reported then
+func (c *cli) send(s *sender, f client.TransactionFile) (*tx.Transaction, error) {
+	if *s.unsigned != "" {
+		return nil, c.writeTransactionFile(*s.unsigned, f)
+	}
+	ks, err := c.openKeystore()
+	if err != nil {
+		return nil, err
+	}
+	key, err := ks.UnlockECDSA(*s.key)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Sign(key); err != nil {
+		return nil, err
+	}
+	if err := c.node.Submit(c.ctx, f.Transaction); err != nil {
+		return nil, err
+	}
+	return &f.Transaction, nil
+}
+
+// TransactionFileData describes a transaction file, State is unsigned, signed or invalid
+type TransactionFileData struct {
+	File        string  `json:"file"`
+	State       string  `json:"state"`
+	Error       string  `json:"error,omitempty"`
+	Hash        string  `json:"hash,omitempty"`
+	Type        string  `json:"type"`
+	FromAddress string  `json:"fromAddress"`
+	To          string  `json:"to"`
+	Fee         float32 `json:"fee"`
+	Timestamp   int64   `json:"timestamp"`
+}
+
+// describeFile checks the transaction of a file, err is the reason if it is invalid
+func describeFile(path string, f client.TransactionFile) (data TransactionFileData, err error) {
+	t := f.Transaction
+	data = TransactionFileData{File: path, Hash: t.Hash, Type: t.TXType, To: t.To, Fee: t.TXFee, Timestamp: t.Timestamp}
+	data.FromAddress, _ = tx.AddressFromPublicKey(t.From)
+	err = f.Check()
+	switch {
+	case err == nil:
+		data.State = "signed"
+	case errors.Is(err, client.ErrUnsigned):
+		data.State = "unsigned"
+		err = nil
+	default:
+		data.State = "invalid"
+		data.Error = err.Error()
+	}
+	return data, err
+}
+
+func (c *cli) printTransactionFile(data TransactionFileData) error {
+	pairs := []string{"File", data.File, "State", data.State}
+	if data.Error != "" {
+		pairs = append(pairs, "Error", data.Error)
+	}
+	if data.Hash != "" {
+		pairs = append(pairs, "Transaction", data.Hash)
+	}
+	to := data.To
+	if to == "" {
+		to = "-"
+	}
+	pairs = append(pairs,
+		"Type", data.Type,
+		"From", data.FromAddress,
+		"To", to,
+		"Fee", fmt.Sprint(data.Fee),
+		"Created", time.Unix(0, data.Timestamp*int64(time.Millisecond)).Format(time.RFC3339))
+	return c.out.fields(data, pairs...)
+}
+
+func (c *cli) writeTransactionFile(path string, f client.TransactionFile) error {
+	data, err := describeFile(path, f)
+	if err != nil {
+		return err
+	}
+	if err := client.WriteTransactionFile(path, f); err != nil {
+		return err
+	}
+	return c.printTransactionFile(data)
+}
+
+func runSign(c *cli, args []string) error {
+	fs := c.newFlagSet()
+	keyName := fs.String("key", "", "name of the ecdsa key the transaction is sent with")
+	if err := parse(fs, args, 2); err != nil {
+		return err
+	}
+	f, err := client.ReadTransactionFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if _, err := describeFile(fs.Arg(0), f); err != nil {
+		return err
+	}
+	ks, err := c.openKeystore()
+	if err != nil {
+		return err
+	}
+	key, err := ks.UnlockECDSA(*keyName)
+	if err != nil {
+		return err
+	}
+	if err := f.Sign(key); err != nil {
+		return err
+	}
+	return c.writeTransactionFile(fs.Arg(1), f)
+}
+
+func runBroadcast(c *cli, args []string) error {
+	fs := c.newFlagSet()
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	f, err := client.ReadTransactionFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	//An invalid transaction is not sent, the node would refuse it anyway
+	if err := f.Check(); err != nil {
+		return err
+	}
+	if err := c.node.Submit(c.ctx, f.Transaction); err != nil {
+		return err
+	}
+	data, _ := describeFile(fs.Arg(0), f)
+	return c.printTransactionFile(data)
+}
+
+func runVerify(c *cli, args []string) error {
+	fs := c.newFlagSet()
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	f, err := client.ReadTransactionFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	data, err := describeFile(fs.Arg(0), f)
+	if printErr := c.printTransactionFile(data); printErr != nil {
+		return printErr
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// printer writes the results of a command as indented json or as a table
+type printer struct {
+	json bool
+	w    io.Writer
+}
+
+// print writes v in json mode, otherwise the rows under the header as a table
+func (p *printer) print(v interface{}, header []string, rows [][]string) error {
+	if p.json {
+		return p.printJSON(v)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	if header != nil {
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// fields writes v in json mode, otherwise one "name: value" line per pair of names and values
+func (p *printer) fields(v interface{}, pairs ...string) error {
+	rows := make([][]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		rows = append(rows, []string{pairs[i] + ":", pairs[i+1]})
+	}
+	return p.print(v, nil, rows)
+}
+
+func (p *printer) printJSON(v interface{}) error {
+	output, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(p.w, string(output))
+	return err
+}
+
+// line writes one json object per line in json mode and text otherwise, used by commands that stream results
+func (p *printer) line(v interface{}, text string) error {
+	if p.json {
+		output, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(p.w, string(output))
+		return err
+	}
+	_, err := fmt.Fprintln(p.w, text)
+	return err
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"../../api"
+	"../../client"
+	"../../transaction"
+)
+
+func runStatus(c *cli, args []string) error {
+	fs := c.newFlagSet()
+	if err := fs.Parse(args); err != nil {
+		return &exitError{code: EXIT_USAGE, err: err}
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return exit(EXIT_USAGE, "expected at most one transaction hash")
+	}
+	if fs.NArg() == 0 {
+		info, err := c.node.NodeInfo(c.ctx)
+		if err != nil {
+			return err
+		}
+		return c.out.fields(info,
+			"Node", info.Addr,
+			"Producer", info.Producer,
+			"Height", strconv.Itoa(int(info.Height)),
+			"Finalized", fmt.Sprintf("%d %v", info.FinalizedHeight, info.FinalizedHash),
+			"Bootstrapped", yesNo(info.Bootstrapped),
+			"Peers", strconv.Itoa(info.Peers))
+	}
+
+	hash := fs.Arg(0)
+	status, err := c.node.Status(c.ctx, hash)
+	if errors.Is(err, client.ErrNotFound) {
+		return exit(EXIT_NOT_FOUND, "transaction %v is unknown to the node", hash)
+	}
+	if err != nil {
+		return err
+	}
+	if status.State == client.STATE_PENDING {
+		return c.out.fields(status, "Transaction", hash, "State", status.State)
+	}
+	return c.out.fields(status, "Transaction", hash, "State", status.State, "Block", fmt.Sprintf("%d %v", status.BlockHeight, status.BlockHash), "Confirmations", strconv.Itoa(int(status.Confirmations)))
+}
+
+// WATCH_RETRY is how long watch waits before subscribing again after the stream ended
+const WATCH_RETRY = 2 * time.Second
+
+func runWatch(c *cli, args []string) error {
+	fs := c.newFlagSet()
+	keyName := fs.String("key", "", "only print transactions concerning this key of the keystore")
+	address := fs.String("address", "", "only print transactions concerning this address")
+	merit := fs.String("merit", "", "only print transactions concerning this merit")
+	txType := fs.String("type", "", "only print transactions of this type, application, acceptance or confirmation")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *keyName != "" && *address != "" {
+		fs.Usage()
+		return exit(EXIT_USAGE, "-key and -address cannot be combined")
+	}
+	filter := client.EventFilter{Address: *address, Merit: *merit, Type: *txType}
+	if *keyName != "" {
+		ks, err := c.openKeystore()
+		if err != nil {
+			return err
+		}
+		info, err := ks.Info(*keyName)
+		if err != nil {
+			return err
+		}
+		filter.Address = info.Address
+	}
+	if filter.Address != "" {
+		if err := tx.ValidateAddress(filter.Address); err != nil {
+			return exit(EXIT_USAGE, "invalid address: %v", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(c.ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	for {
+		err := c.node.Events(ctx, filter, func(e api.Event) error {
+			text := fmt.Sprintf("%-8v\t%v\t%-12v\tfrom %v\tto %v", e.Event, e.Hash, e.TXType, e.FromAddress, e.To)
+			if e.Event != api.EVENT_PENDING {
+				text += fmt.Sprintf("\tin block %d", e.BlockHeight)
+			}
+			return c.out.line(e, text)
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		//A refused subscription does not get better by retrying
+		var rejected *client.RejectedError
+		if errors.As(err, &rejected) {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "jobmarket watch: "+err.Error())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(WATCH_RETRY):
+		}
+	}
+}
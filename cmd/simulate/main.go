@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"../../simulator"
+)
+
+func main() {
+	only := flag.String("run", "", "name of the only scenario to run")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "seed of the simulated message loss and latency")
+	flag.Parse()
+
+	failed := 0
+	for _, scenario := range simulator.Scenarios {
+		if *only != "" && scenario.Name != *only {
+			continue
+		}
+		start := time.Now()
+		err := scenario.Run(simulator.NewSimulator(*seed))
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL %v (%v, seed %d): %v\n", scenario.Name, time.Since(start).Round(time.Millisecond), *seed, err)
+			continue
+		}
+		fmt.Printf("ok   %v (%v)\n", scenario.Name, time.Since(start).Round(time.Millisecond))
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,256 @@
+// Package codec implements the canonical binary encoding of blocks and transactions. The encoding is used for
+// hashing, signing, storage and transfer between nodes, json is only used to present them on the api.
+//
+// Every encoding starts with a kind byte naming what is encoded and the VERSION of the encoding. Integers are
+// fixed-width big-endian, floats are their IEEE 754 bits, strings, byte slices and big integers are prefixed by
+// their length as an unsigned varint and lists by their number of elements. The same value always has the same
+// encoding, so hashes do not depend on field order or number formatting.
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// VERSION is the version of the encoding written by this package, older versions stay decodable
+const VERSION = 1
+
+// Kinds of encoded values, a reader refuses a value of another kind
+const (
+	KIND_TRANSACTION          = 0x01 // a transaction including its hash and signature
+	KIND_UNSIGNED_TRANSACTION = 0x02 // the fields of a transaction that are hashed and signed
+	KIND_BLOCK_HEADER         = 0x03 // the fields of a block header that are hashed
+	KIND_BLOCK                = 0x04 // a block including its transactions
+	KIND_BLOCKCHAIN           = 0x05 // all blocks of a chain
+	KIND_TRANSACTIONS         = 0x06 // a list of transactions, e.g. the persisted mempool
+	KIND_SEAL                 = 0x07 // the block hash and nonce hashed by proof of work
+)
+
+var ErrKind = errors.New("codec: unexpected kind")
+var ErrVersion = errors.New("codec: unsupported version")
+var ErrTruncated = errors.New("codec: truncated input")
+var ErrTrailing = errors.New("codec: trailing bytes")
+
+// Writer builds the encoding of one value
+type Writer struct {
+	buf bytes.Buffer
+}
+
+// NewWriter starts the encoding of a value of the given kind
+func NewWriter(kind byte) *Writer {
+	w := new(Writer)
+	w.buf.WriteByte(kind)
+	w.buf.WriteByte(VERSION)
+	return w
+}
+
+func (w *Writer) Uint8(v uint8) {
+	w.buf.WriteByte(v)
+}
+
+func (w *Writer) Bool(v bool) {
+	if v {
+		w.buf.WriteByte(1)
+	} else {
+		w.buf.WriteByte(0)
+	}
+}
+
+func (w *Writer) Int32(v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	w.buf.Write(b[:])
+}
+
+func (w *Writer) Int64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	w.buf.Write(b[:])
+}
+
+func (w *Writer) Float32(v float32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], math.Float32bits(v))
+	w.buf.Write(b[:])
+}
+
+// Len writes the length of the following bytes or the number of the following list elements
+func (w *Writer) Len(n int) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf.Write(b[:binary.PutUvarint(b[:], uint64(n))])
+}
+
+func (w *Writer) Bytes(v []byte) {
+	w.Len(len(v))
+	w.buf.Write(v)
+}
+
+func (w *Writer) String(v string) {
+	w.Len(len(v))
+	w.buf.WriteString(v)
+}
+
+// BigInt writes the absolute value of v, nil is written like zero
+func (w *Writer) BigInt(v *big.Int) {
+	if v == nil {
+		w.Bytes(nil)
+		return
+	}
+	w.Bytes(v.Bytes())
+}
+
+// Encoded returns the encoding written so far
+func (w *Writer) Encoded() []byte {
+	return w.buf.Bytes()
+}
+
+// Reader decodes one value. The first error is kept and returned by Close, the reads after it return zero values,
+// so a decoder can read all fields and check the error once
+type Reader struct {
+	data    []byte
+	version uint8
+	err     error
+}
+
+// NewReader starts decoding a value of the given kind
+func NewReader(data []byte, kind byte) *Reader {
+	r := &Reader{data: data}
+	if len(data) < 2 {
+		r.err = ErrTruncated
+		return r
+	}
+	if data[0] != kind {
+		r.err = fmt.Errorf("%w %#x, want %#x", ErrKind, data[0], kind)
+		return r
+	}
+	r.version = data[1]
+	if r.version < 1 || r.version > VERSION {
+		r.err = fmt.Errorf("%w %d", ErrVersion, r.version)
+		return r
+	}
+	r.data = data[2:]
+	return r
+}
+
+// Version returns the encoding version of the value
+func (r *Reader) Version() uint8 {
+	return r.version
+}
+
+func (r *Reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = ErrTruncated
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *Reader) Uint8() uint8 {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *Reader) Bool() bool {
+	switch v := r.Uint8(); v {
+	case 0:
+		return false
+	case 1:
+		return true
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("codec: invalid bool %d", v)
+		}
+		return false
+	}
+}
+
+func (r *Reader) Int32() int32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (r *Reader) Int64() int64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+func (r *Reader) Float32() float32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return math.Float32frombits(binary.BigEndian.Uint32(b))
+}
+
+// Len reads a length or a number of list elements, it cannot exceed the remaining input since every element
+// takes at least one byte
+func (r *Reader) Len() int {
+	if r.err != nil {
+		return 0
+	}
+	n, size := binary.Uvarint(r.data)
+	if size <= 0 {
+		r.err = ErrTruncated
+		return 0
+	}
+	r.data = r.data[size:]
+	if n > uint64(len(r.data)) {
+		r.err = ErrTruncated
+		return 0
+	}
+	return int(n)
+}
+
+func (r *Reader) Bytes() []byte {
+	n := r.Len()
+	b := r.next(n)
+	if b == nil {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
+
+func (r *Reader) String() string {
+	n := r.Len()
+	return string(r.next(n))
+}
+
+// BigInt reads a big integer, an empty one is returned as nil
+func (r *Reader) BigInt() *big.Int {
+	b := r.Bytes()
+	if len(b) == 0 {
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+// Err returns the first error of the reads so far
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Close returns the first error of the reads, or ErrTrailing if the value did not use all input
+func (r *Reader) Close() error {
+	if r.err == nil && len(r.data) > 0 {
+		r.err = ErrTrailing
+	}
+	return r.err
+}
@@ -0,0 +1,159 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+// sample writes one field of every type, readSample reads them back in the same order
+func sample() []byte {
+	w := NewWriter(KIND_TRANSACTION)
+	w.Uint8(7)
+	w.Bool(true)
+	w.Int32(-2)
+	w.Int64(1)
+	w.Float32(1.5)
+	w.String("ab")
+	w.Bytes(nil)
+	w.BigInt(big.NewInt(256))
+	w.BigInt(nil)
+	return w.Encoded()
+}
+
+type sampleValue struct {
+	u8   uint8
+	b    bool
+	i32  int32
+	i64  int64
+	f32  float32
+	s    string
+	buf  []byte
+	big  *big.Int
+	none *big.Int
+}
+
+func readSample(r *Reader) sampleValue {
+	return sampleValue{r.Uint8(), r.Bool(), r.Int32(), r.Int64(), r.Float32(), r.String(), r.Bytes(), r.BigInt(), r.BigInt()}
+}
+
+func TestSampleEncoding(t *testing.T) {
+	//The encoding is hashed and signed, any change to it breaks the existing chains
+	want := "0101" + "07" + "01" + "fffffffe" + "0000000000000001" + "3fc00000" + "026162" + "00" + "020100" + "00"
+	if got := hex.EncodeToString(sample()); got != want {
+		t.Fatalf("encoding %v, want %v", got, want)
+	}
+	if !bytes.Equal(sample(), sample()) {
+		t.Fatal("the same value encodes differently")
+	}
+
+	r := NewReader(sample(), KIND_TRANSACTION)
+	v := readSample(r)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if v.u8 != 7 || !v.b || v.i32 != -2 || v.i64 != 1 || v.f32 != 1.5 || v.s != "ab" || len(v.buf) != 0 ||
+		v.big.Cmp(big.NewInt(256)) != 0 || v.none != nil {
+		t.Fatalf("decoded %+v", v)
+	}
+	if r.Version() != VERSION {
+		t.Fatalf("version %d, want %d", r.Version(), VERSION)
+	}
+}
+
+func TestFloat32(t *testing.T) {
+	tests := []struct {
+		name  string
+		value float32
+		want  string
+	}{
+		{"zero", 0, "00000000"},
+		{"negative zero", float32(math.Copysign(0, -1)), "80000000"},
+		{"tenth", 0.1, "3dcccccd"},
+		{"smallest", math.SmallestNonzeroFloat32, "00000001"},
+		{"largest", math.MaxFloat32, "7f7fffff"},
+		{"infinity", float32(math.Inf(-1)), "ff800000"},
+		{"nan", float32(math.NaN()), "7fc00000"},
+	}
+	for _, test := range tests {
+		w := NewWriter(KIND_TRANSACTION)
+		w.Float32(test.value)
+		if got := hex.EncodeToString(w.Encoded()[2:]); got != test.want {
+			t.Errorf("%v: encoding %v, want %v", test.name, got, test.want)
+		}
+		r := NewReader(w.Encoded(), KIND_TRANSACTION)
+		got := r.Float32()
+		if err := r.Close(); err != nil {
+			t.Errorf("%v: %v", test.name, err)
+		}
+		//Compare the bits, NaN is not equal to itself and 0 is equal to -0
+		if math.Float32bits(got) != math.Float32bits(test.value) {
+			t.Errorf("%v: decoded %v, want %v", test.name, got, test.value)
+		}
+	}
+}
+
+func TestMalformedInput(t *testing.T) {
+	valid := sample()
+	withVersion := func(version byte) []byte {
+		data := append([]byte(nil), valid...)
+		data[1] = version
+		return data
+	}
+	tests := []struct {
+		name string
+		data []byte
+		kind byte
+		want error
+	}{
+		{"empty", nil, KIND_TRANSACTION, ErrTruncated},
+		{"kind only", valid[:1], KIND_TRANSACTION, ErrTruncated},
+		{"header only", valid[:2], KIND_TRANSACTION, ErrTruncated},
+		{"cut in an integer", valid[:6], KIND_TRANSACTION, ErrTruncated},
+		{"cut in a string", valid[:len(valid)-6], KIND_TRANSACTION, ErrTruncated},
+		{"cut before the last field", valid[:len(valid)-1], KIND_TRANSACTION, ErrTruncated},
+		{"trailing byte", append(append([]byte(nil), valid...), 0), KIND_TRANSACTION, ErrTrailing},
+		{"wrong kind", valid, KIND_BLOCK, ErrKind},
+		{"version 0", withVersion(0), KIND_TRANSACTION, ErrVersion},
+		{"future version", withVersion(VERSION + 1), KIND_TRANSACTION, ErrVersion},
+	}
+	for _, test := range tests {
+		r := NewReader(test.data, test.kind)
+		readSample(r)
+		if err := r.Close(); !errors.Is(err, test.want) {
+			t.Errorf("%v: error %v, want %v", test.name, err, test.want)
+		}
+	}
+
+	//Every proper prefix of a value is refused
+	for n := 0; n < len(valid); n++ {
+		r := NewReader(valid[:n], KIND_TRANSACTION)
+		readSample(r)
+		if err := r.Close(); err == nil {
+			t.Errorf("prefix of %d bytes decoded", n)
+		}
+	}
+}
+
+func TestReaderRefusesImpossibleValues(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		read func(r *Reader)
+	}{
+		{"length beyond the input", "05616263", func(r *Reader) { _ = r.String() }},
+		{"unterminated length", "ff", func(r *Reader) { _ = r.Bytes() }},
+		{"bool other than 0 or 1", "02", func(r *Reader) { _ = r.Bool() }},
+	}
+	for _, test := range tests {
+		body, _ := hex.DecodeString(test.body)
+		r := NewReader(append([]byte{KIND_TRANSACTION, VERSION}, body...), KIND_TRANSACTION)
+		test.read(r)
+		if r.Err() == nil {
+			t.Errorf("%v: no error", test.name)
+		}
+	}
+}
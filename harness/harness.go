@@ -0,0 +1,239 @@
+// Package harness runs a network of jobmarket nodes inside one process, each node served by an httptest server,
+// so that integration tests can drive several nodes without ports or processes to manage:
+//
+//	network, err := harness.NewNetwork(5, nil)
+//	defer network.Close()
+//	network.SubmitTx(0, t)
+//	err = network.WaitForTx(t.Hash, time.Minute)
+package harness
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"../api"
+	"../models"
+	"../p3"
+	"../transaction"
+)
+
+// PollInterval is how often the wait helpers check the nodes
+var PollInterval = 100 * time.Millisecond
+
+// Instance is one node of the network together with the server it is reachable at
+type Instance struct {
+	Node   *p3.Node
+	Server *httptest.Server
+	Addr   string
+}
+
+// Network is a set of nodes that bootstrap from the first one
+type Network struct {
+	Instances []*Instance
+	cancel    context.CancelFunc
+}
+
+// Config returns the configuration used for harness nodes: a low difficulty and short intervals so that blocks are
+// mined and gossiped within seconds, and no storage so that nothing is left behind
+func Config() p3.Config {
+	config := p3.DefaultConfig()
+	config.Network.HeartBeatInterval = p3.Duration(500 * time.Millisecond)
+	config.Network.RetryBackoff = p3.Duration(200 * time.Millisecond)
+	config.Network.PeerTimeout = p3.Duration(2 * time.Second)
+	config.Mining.Difficulty = "000"
+	config.Mempool.EmptyWait = p3.Duration(200 * time.Millisecond)
+	config.API.ShutdownTimeout = p3.Duration(time.Second)
+	//Every node gets a fresh key, the nodes of a process would share the default key file
+	config.Storage = p3.StorageConfig{}
+	config.Mining.MinerKeyFile = ""
+	return config
+}
+
+// NewNetwork launches n nodes, node 0 starts the chain and every other node uses it as seed.
+// configure, if not nil, can adjust the configuration of each node before it is created
+func NewNetwork(n int, configure func(i int, config *p3.Config)) (*Network, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	network := &Network{cancel: cancel}
+	for i := 0; i < n; i++ {
+		server := httptest.NewUnstartedServer(nil)
+		addr := "http://" + server.Listener.Addr().String()
+		_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+		config := Config()
+		config.API.Port = port
+		config.Network.AdvertiseAddr = addr
+		if i > 0 {
+			config.Network.Seeds = []string{network.Instances[0].Addr}
+		}
+		if configure != nil {
+			configure(i, &config)
+		}
+		if err := config.Validate(); err != nil {
+			server.Close()
+			network.Close()
+			return nil, fmt.Errorf("node %d: %v", i, err)
+		}
+		node, err := p3.NewNode(config)
+		if err != nil {
+			server.Close()
+			network.Close()
+			return nil, fmt.Errorf("node %d: %v", i, err)
+		}
+		server.Config.Handler = node.Handler()
+		server.Start()
+		network.Instances = append(network.Instances, &Instance{Node: node, Server: server, Addr: addr})
+	}
+	for _, instance := range network.Instances {
+		instance.Node.Run(ctx)
+	}
+	return network, nil
+}
+
+// Close stops all nodes, the servers are closed first so that no request is in flight while the nodes stop
+func (network *Network) Close() {
+	for _, instance := range network.Instances {
+		//Event streams never finish on their own, Close would wait for them
+		instance.Server.CloseClientConnections()
+		instance.Server.Close()
+	}
+	network.cancel()
+	for _, instance := range network.Instances {
+		instance.Node.Stop()
+	}
+}
+
+// NewTransaction builds a transaction signed by key
+func NewTransaction(key *ecdsa.PrivateKey, txType string, to string, payload string, fee float32) tx.Transaction {
+	t := tx.Transaction{
+		Version:   tx.CURRENT_VERSION,
+		From:      tx.EncodeECDSAPublicKey(&key.PublicKey),
+		To:        to,
+		TXType:    txType,
+		TXFee:     fee,
+		Payload:   payload,
+		Timestamp: time.Now().UnixNano() / 1000000,
+	}
+	t.Hash = t.GenHash()
+	t.Sign(key)
+	return t
+}
+
+// NewApplication returns a signed application publishing merit, the identity signed with it is left empty
+func NewApplication(key *ecdsa.PrivateKey, merit models.Merit, fee float32) (tx.Transaction, error) {
+	application := models.TimestampedApplication{
+		Application: models.Application{Merit: merit},
+		Timestamp:   time.Now().UnixNano() / 1000000,
+	}
+	applicationBytes, err := json.Marshal(application)
+	if err != nil {
+		return tx.Transaction{}, err
+	}
+	hash := sha256.Sum256(applicationBytes)
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash[:])
+	if err != nil {
+		return tx.Transaction{}, err
+	}
+	payload := &tx.ApplicationPayload{SignedMerit: models.SignedMerit{
+		Merit:     merit,
+		Hash:      hex.EncodeToString(hash[:]),
+		Timestamp: application.Timestamp,
+		Signature: models.ECDSASignature{R: r, S: s},
+	}}
+	encoded, err := payload.Encode()
+	if err != nil {
+		return tx.Transaction{}, err
+	}
+	return NewTransaction(key, tx.TYPE_APPLICATION, "", encoded, fee), nil
+}
+
+// SubmitTx posts a transaction to node i
+func (network *Network) SubmitTx(i int, t tx.Transaction) error {
+	tjson, err := t.EncodeToJSON()
+	if err != nil {
+		return err
+	}
+	res, err := http.Post(network.Instances[i].Addr+"/transaction", "application/json", bytes.NewBufferString(tjson))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("node %d answered %v", i, res.Status)
+	}
+	return nil
+}
+
+// WaitForHeight waits until every node has a chain of at least height blocks
+func (network *Network) WaitForHeight(height int32, timeout time.Duration) error {
+	return network.waitFor(timeout, func(i int) (bool, string) {
+		current := network.Instances[i].Node.Info().Height
+		return current >= height, fmt.Sprintf("height %d, want %d", current, height)
+	})
+}
+
+// WaitForTx waits until every node has the transaction in its canonical chain
+func (network *Network) WaitForTx(hash string, timeout time.Duration) error {
+	return network.waitFor(timeout, func(i int) (bool, string) {
+		transactions, err := network.Transactions(i)
+		if err != nil {
+			return false, err.Error()
+		}
+		for _, t := range transactions {
+			if t.Hash == hash {
+				return true, ""
+			}
+		}
+		return false, "transaction " + hash + " not in canonical chain"
+	})
+}
+
+// Transactions returns the canonical transactions of node i as served by its /transactions endpoint
+func (network *Network) Transactions(i int) ([]api.ChainTransaction, error) {
+	res, err := http.Get(network.Instances[i].Addr + "/transactions")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	transactions := make([]api.ChainTransaction, 0)
+	if err := json.Unmarshal(body, &transactions); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// waitFor polls done for every node until all of them are done, the error names the first node that is not
+func (network *Network) waitFor(timeout time.Duration, done func(i int) (bool, string)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		pending := -1
+		reason := ""
+		for i := range network.Instances {
+			if ok, why := done(i); !ok {
+				pending, reason = i, why
+				break
+			}
+		}
+		if pending < 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("node %d after %v: %v", pending, timeout, reason)
+		}
+		time.Sleep(PollInterval)
+	}
+}
@@ -0,0 +1,69 @@
+package harness
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"../models"
+)
+
+func TestNetworkMinesSubmittedTransaction(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a cluster of nodes")
+	}
+	network, err := NewNetwork(3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := false
+	defer func() {
+		if !closed {
+			network.Close()
+		}
+	}()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	application, err := NewApplication(key, models.Merit{Experience: []string{"harness"}}, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := network.SubmitTx(1, application); err != nil {
+		t.Fatal(err)
+	}
+	//WaitForTx waits for every node, so the transaction has to reach the nodes it was not submitted to
+	if err := network.WaitForTx(application.Hash, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	transactions, err := network.Transactions(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, ct := range transactions {
+		found = found || ct.Hash == application.Hash
+	}
+	if !found {
+		t.Fatalf("node 2 does not list transaction %v", application.Hash)
+	}
+
+	stopped := make(chan bool)
+	go func() {
+		network.Close()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		closed = true
+	case <-time.After(30 * time.Second):
+		t.Fatal("network did not stop within 30s")
+	}
+	if _, err := network.Transactions(0); err == nil {
+		t.Fatal("node 0 still answers after Close")
+	}
+}
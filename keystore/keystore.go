@@ -0,0 +1,467 @@
+// Package keystore keeps the private keys of the clients encrypted with a passphrase. Every key is a json file in
+// the keystore directory named after the key. The public part of a key is stored in the clear so that keys can be
+// listed without the passphrase, the private key is encrypted with AES-256-GCM under a key derived from the
+// passphrase by scrypt.
+package keystore
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+
+	"../transaction"
+)
+
+// Types of the keys in a keystore, ecdsa keys sign transactions and rsa keys receive the identity of applicants
+const (
+	KEY_ECDSA = "ecdsa"
+	KEY_RSA   = "rsa"
+)
+
+// VERSION is the version of the key file format written by this package
+const VERSION = 1
+
+// Parameters of the key derivation written to new key files, the parameters of a file are read from the file
+const (
+	SCRYPT_N        = 1 << 15
+	SCRYPT_R        = 8
+	SCRYPT_P        = 1
+	SCRYPT_KEY_SIZE = 32
+)
+
+// Bounds of the key derivation parameters read from a key file, a file asking for more memory or time than this is
+// refused before deriving the key. Scrypt needs 128 * N * r bytes of memory, N and r are bounded together by
+// MAX_SCRYPT_MEMORY as well
+const (
+	MAX_SCRYPT_N      = 1 << 20
+	MAX_SCRYPT_R      = 32
+	MAX_SCRYPT_P      = 16
+	MAX_SCRYPT_MEMORY = 1 << 30
+)
+
+// RSA_BITS is the size of generated rsa keys
+const RSA_BITS = 2048
+
+// PASSPHRASE_ENV names the environment variable read instead of prompting for the passphrase, e.g. in scripts
+const PASSPHRASE_ENV = "JOBMARKET_PASSPHRASE"
+
+var ErrNotFound = errors.New("key not found")
+var ErrExists = errors.New("key already exists")
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted key file")
+var ErrKeyType = errors.New("unexpected key type")
+
+// stdin is shared by the prompts so that input buffered by one prompt is not lost for the next
+var stdin = bufio.NewReader(os.Stdin)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// KeyInfo is the public part of a key, Address is only set for ecdsa keys
+type KeyInfo struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Address   string `json:"address,omitempty"`
+	PublicKey string `json:"publicKey"`
+	Created   int64  `json:"created"`
+}
+
+// keyFile is the content of a key file, Crypto holds the encrypted private key
+type keyFile struct {
+	Version int `json:"version"`
+	KeyInfo
+	Crypto cryptoParams `json:"crypto"`
+}
+
+type cryptoParams struct {
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       string `json:"salt"`
+	Cipher     string `json:"cipher"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// Keystore is a directory of key files
+type Keystore struct {
+	dir string
+}
+
+// DefaultDir returns the keystore directory used when none is given
+func DefaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "keystore"
+	}
+	return filepath.Join(home, ".jobmarket", "keystore")
+}
+
+// Open returns the keystore in dir, the directory is created if it does not exist yet
+func Open(dir string) (*Keystore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Keystore{dir: dir}, nil
+}
+
+// List returns the public part of all keys ordered by name
+func (ks *Keystore) List() ([]KeyInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(ks.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]KeyInfo, 0)
+	for _, path := range paths {
+		kf, err := readKeyFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", path, err)
+		}
+		keys = append(keys, kf.KeyInfo)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys, nil
+}
+
+// Info returns the public part of a key
+func (ks *Keystore) Info(name string) (KeyInfo, error) {
+	kf, err := ks.read(name)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	return kf.KeyInfo, nil
+}
+
+// Generate creates a new key of keyType and stores it encrypted with passphrase
+func (ks *Keystore) Generate(name string, keyType string, passphrase string) (KeyInfo, error) {
+	switch keyType {
+	case KEY_ECDSA:
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return KeyInfo{}, err
+		}
+		return ks.storeECDSA(name, privateKey, passphrase)
+	case KEY_RSA:
+		privateKey, err := rsa.GenerateKey(rand.Reader, RSA_BITS)
+		if err != nil {
+			return KeyInfo{}, err
+		}
+		return ks.storeRSA(name, privateKey, passphrase)
+	}
+	return KeyInfo{}, fmt.Errorf("%w %q", ErrKeyType, keyType)
+}
+
+// Import stores a PEM encoded ecdsa or rsa private key encrypted with passphrase. Keys written by the former
+// keygen commands are labelled PRIVATE KEY whatever their type, their type is detected from the content
+func (ks *Keystore) Import(name string, pemEncoded []byte, passphrase string) (KeyInfo, error) {
+	block, _ := pem.Decode(pemEncoded)
+	if block == nil {
+		return KeyInfo{}, errors.New("no pem block found")
+	}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		privateKey, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return KeyInfo{}, err
+		}
+		return ks.storeECDSA(name, privateKey, passphrase)
+	case "RSA PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return KeyInfo{}, err
+		}
+		return ks.storeRSA(name, privateKey, passphrase)
+	case "PRIVATE KEY":
+		if privateKey, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+			return ks.storeECDSA(name, privateKey, passphrase)
+		}
+		if privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+			return ks.storeRSA(name, privateKey, passphrase)
+		}
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return KeyInfo{}, err
+		}
+		switch privateKey := privateKey.(type) {
+		case *ecdsa.PrivateKey:
+			return ks.storeECDSA(name, privateKey, passphrase)
+		case *rsa.PrivateKey:
+			return ks.storeRSA(name, privateKey, passphrase)
+		}
+	}
+	return KeyInfo{}, fmt.Errorf("%w %q", ErrKeyType, block.Type)
+}
+
+// Export returns the decrypted private key in PEM encoding, labelled EC PRIVATE KEY or RSA PRIVATE KEY
+func (ks *Keystore) Export(name string, passphrase string) ([]byte, error) {
+	kf, der, err := ks.decrypt(name, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	switch kf.Type {
+	case KEY_ECDSA:
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+	case KEY_RSA:
+		return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: der}), nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrKeyType, kf.Type)
+}
+
+// ECDSAKey decrypts an ecdsa key
+func (ks *Keystore) ECDSAKey(name string, passphrase string) (*ecdsa.PrivateKey, error) {
+	kf, der, err := ks.decrypt(name, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if kf.Type != KEY_ECDSA {
+		return nil, fmt.Errorf("%w: %v is an %v key", ErrKeyType, name, kf.Type)
+	}
+	return x509.ParseECPrivateKey(der)
+}
+
+// RSAKey decrypts an rsa key
+func (ks *Keystore) RSAKey(name string, passphrase string) (*rsa.PrivateKey, error) {
+	kf, der, err := ks.decrypt(name, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if kf.Type != KEY_RSA {
+		return nil, fmt.Errorf("%w: %v is an %v key", ErrKeyType, name, kf.Type)
+	}
+	return x509.ParsePKCS1PrivateKey(der)
+}
+
+func (ks *Keystore) storeECDSA(name string, privateKey *ecdsa.PrivateKey, passphrase string) (KeyInfo, error) {
+	der, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	info := KeyInfo{
+		Name:      name,
+		Type:      KEY_ECDSA,
+		Address:   tx.Address(&privateKey.PublicKey),
+		PublicKey: tx.EncodeECDSAPublicKey(&privateKey.PublicKey),
+	}
+	return ks.store(info, der, passphrase)
+}
+
+func (ks *Keystore) storeRSA(name string, privateKey *rsa.PrivateKey, passphrase string) (KeyInfo, error) {
+	info := KeyInfo{
+		Name:      name,
+		Type:      KEY_RSA,
+		PublicKey: tx.EncodeRSAPublicKey(&privateKey.PublicKey),
+	}
+	return ks.store(info, x509.MarshalPKCS1PrivateKey(privateKey), passphrase)
+}
+
+// store encrypts der and writes the key file, an existing key is never overwritten
+func (ks *Keystore) store(info KeyInfo, der []byte, passphrase string) (KeyInfo, error) {
+	path, err := ks.path(info.Name)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	if passphrase == "" {
+		return KeyInfo{}, errors.New("empty passphrase")
+	}
+	info.Created = time.Now().Unix()
+	kf := keyFile{Version: VERSION, KeyInfo: info}
+	kf.Crypto = cryptoParams{KDF: "scrypt", N: SCRYPT_N, R: SCRYPT_R, P: SCRYPT_P, Cipher: "aes-256-gcm"}
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return KeyInfo{}, err
+	}
+	kf.Crypto.Salt = hex.EncodeToString(salt)
+	aead, err := kf.aead(passphrase)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return KeyInfo{}, err
+	}
+	kf.Crypto.Nonce = hex.EncodeToString(nonce)
+	kf.Crypto.Ciphertext = hex.EncodeToString(aead.Seal(nil, nonce, der, kf.additionalData()))
+
+	content, err := json.MarshalIndent(kf, "", "\t")
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return KeyInfo{}, fmt.Errorf("%w: %v", ErrExists, info.Name)
+	}
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(path)
+		return KeyInfo{}, err
+	}
+	return info, f.Close()
+}
+
+// decrypt returns the key file and the decrypted private key in its x509 encoding
+func (ks *Keystore) decrypt(name string, passphrase string) (keyFile, []byte, error) {
+	kf, err := ks.read(name)
+	if err != nil {
+		return keyFile{}, nil, err
+	}
+	aead, err := kf.aead(passphrase)
+	if err != nil {
+		return keyFile{}, nil, err
+	}
+	nonce, err := hex.DecodeString(kf.Crypto.Nonce)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return keyFile{}, nil, ErrWrongPassphrase
+	}
+	ciphertext, err := hex.DecodeString(kf.Crypto.Ciphertext)
+	if err != nil {
+		return keyFile{}, nil, ErrWrongPassphrase
+	}
+	der, err := aead.Open(nil, nonce, ciphertext, kf.additionalData())
+	if err != nil {
+		return keyFile{}, nil, ErrWrongPassphrase
+	}
+	return kf, der, nil
+}
+
+func (ks *Keystore) read(name string) (keyFile, error) {
+	path, err := ks.path(name)
+	if err != nil {
+		return keyFile{}, err
+	}
+	kf, err := readKeyFile(path)
+	if os.IsNotExist(err) {
+		return keyFile{}, fmt.Errorf("%w: %v", ErrNotFound, name)
+	}
+	return kf, err
+}
+
+func (ks *Keystore) path(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf("invalid key name %q, use letters, digits, '.', '_' and '-'", name)
+	}
+	return filepath.Join(ks.dir, name+".json"), nil
+}
+
+func readKeyFile(path string) (keyFile, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return keyFile{}, err
+	}
+	var kf keyFile
+	if err := json.Unmarshal(content, &kf); err != nil {
+		return keyFile{}, err
+	}
+	if kf.Version != VERSION {
+		return keyFile{}, fmt.Errorf("unsupported key file version %d", kf.Version)
+	}
+	return kf, nil
+}
+
+// aead derives the encryption key from the passphrase with the parameters stored in the key file
+func (kf *keyFile) aead(passphrase string) (cipher.AEAD, error) {
+	if kf.Crypto.KDF != "scrypt" || kf.Crypto.Cipher != "aes-256-gcm" {
+		return nil, fmt.Errorf("unsupported key encryption %v/%v", kf.Crypto.KDF, kf.Crypto.Cipher)
+	}
+	n, r, p := kf.Crypto.N, kf.Crypto.R, kf.Crypto.P
+	if n < 2 || n > MAX_SCRYPT_N || n&(n-1) != 0 || r < 1 || r > MAX_SCRYPT_R || p < 1 || p > MAX_SCRYPT_P ||
+		128*int64(n)*int64(r) > MAX_SCRYPT_MEMORY {
+		return nil, fmt.Errorf("unsupported scrypt parameters n=%d r=%d p=%d", n, r, p)
+	}
+	salt, err := hex.DecodeString(kf.Crypto.Salt)
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, kf.Crypto.N, kf.Crypto.R, kf.Crypto.P, SCRYPT_KEY_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// additionalData binds the public part of the key file to the ciphertext, so that it cannot be swapped
+func (kf *keyFile) additionalData() []byte {
+	return []byte(kf.Name + "\x00" + kf.Type + "\x00" + kf.PublicKey)
+}
+
+// ReadPassphrase returns the passphrase from PASSPHRASE_ENV if set, otherwise it prompts for it. The passphrase is
+// not echoed if stdin is a terminal, other input such as a pipe is read line by line
+func ReadPassphrase(prompt string) (string, error) {
+	if passphrase, ok := os.LookupEnv(PASSPHRASE_ENV); ok {
+		return passphrase, nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		passphrase, err := term.ReadPassword(fd)
+		//The newline typed by the user is not echoed either
+		fmt.Fprintln(os.Stderr)
+		return string(passphrase), err
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// ReadNewPassphrase asks for the passphrase of a new key twice, PASSPHRASE_ENV is used as is
+func ReadNewPassphrase() (string, error) {
+	if passphrase, ok := os.LookupEnv(PASSPHRASE_ENV); ok {
+		return passphrase, nil
+	}
+	passphrase, err := ReadPassphrase("New passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	repeated, err := ReadPassphrase("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase != repeated {
+		return "", errors.New("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// UnlockECDSA asks for the passphrase of an ecdsa key and decrypts it
+func (ks *Keystore) UnlockECDSA(name string) (*ecdsa.PrivateKey, error) {
+	passphrase, err := ReadPassphrase("Passphrase for " + name + ": ")
+	if err != nil {
+		return nil, err
+	}
+	return ks.ECDSAKey(name, passphrase)
+}
+
+// UnlockRSA asks for the passphrase of an rsa key and decrypts it
+func (ks *Keystore) UnlockRSA(name string) (*rsa.PrivateKey, error) {
+	passphrase, err := ReadPassphrase("Passphrase for " + name + ": ")
+	if err != nil {
+		return nil, err
+	}
+	return ks.RSAKey(name, passphrase)
+}
@@ -0,0 +1,156 @@
+package keystore
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"../transaction"
+)
+
+func TestGenerateAndUnlock(t *testing.T) {
+	ks, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ks.Generate("alice", KEY_ECDSA, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ks.ECDSAKey("alice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Address != tx.Address(&key.PublicKey) || info.PublicKey != tx.EncodeECDSAPublicKey(&key.PublicKey) {
+		t.Fatalf("info %+v does not describe the stored key", info)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		call func() error
+	}{
+		{"wrong passphrase", ErrWrongPassphrase, func() error {
+			_, err := ks.ECDSAKey("alice", "guess")
+			return err
+		}},
+		{"missing key", ErrNotFound, func() error {
+			_, err := ks.ECDSAKey("bob", "secret")
+			return err
+		}},
+		{"existing name", ErrExists, func() error {
+			_, err := ks.Generate("alice", KEY_ECDSA, "other")
+			return err
+		}},
+		{"wrong key type", ErrKeyType, func() error {
+			_, err := ks.RSAKey("alice", "secret")
+			return err
+		}},
+		{"unknown key type", ErrKeyType, func() error {
+			_, err := ks.Generate("carol", "dsa", "secret")
+			return err
+		}},
+		{"name outside the keystore", nil, func() error {
+			_, err := ks.Generate("../alice", KEY_ECDSA, "secret")
+			return err
+		}},
+		{"empty passphrase", nil, func() error {
+			_, err := ks.Generate("dave", KEY_ECDSA, "")
+			return err
+		}},
+	}
+	for _, test := range tests {
+		err := test.call()
+		if err == nil || (test.err != nil && !errors.Is(err, test.err)) {
+			t.Errorf("%v: error %v, want %v", test.name, err, test.err)
+		}
+	}
+
+	keys, err := ks.List()
+	if err != nil || len(keys) != 1 || keys[0] != info {
+		t.Fatalf("List = %+v, %v, want only %+v", keys, err, info)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	ks, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := ks.Generate("alice", KEY_ECDSA, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, err := ks.Export("alice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ks.Import("copy", exported, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Address != original.Address || imported.Type != KEY_ECDSA {
+		t.Fatalf("imported %+v, want the key of %+v", imported, original)
+	}
+	if _, err := ks.Import("garbage", []byte("not pem"), "secret"); err == nil {
+		t.Fatal("imported a file without a pem block")
+	}
+}
+
+func TestTamperedKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Generate("alice", KEY_ECDSA, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "alice.json")
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(kf *keyFile)
+		err    error
+	}{
+		{"replaced public key", func(kf *keyFile) { kf.PublicKey = flipHex(kf.PublicKey) }, ErrWrongPassphrase},
+		{"flipped ciphertext", func(kf *keyFile) {
+			kf.Crypto.Ciphertext = flipHex(kf.Crypto.Ciphertext)
+		}, ErrWrongPassphrase},
+		{"short nonce", func(kf *keyFile) { kf.Crypto.Nonce = "00" }, ErrWrongPassphrase},
+		//A file asking for 4 GiB of memory is refused before the key derivation starts
+		{"scrypt memory", func(kf *keyFile) { kf.Crypto.N, kf.Crypto.R = MAX_SCRYPT_N, MAX_SCRYPT_R }, nil},
+		{"scrypt n not a power of two", func(kf *keyFile) { kf.Crypto.N = 3 }, nil},
+		{"scrypt p", func(kf *keyFile) { kf.Crypto.P = MAX_SCRYPT_P + 1 }, nil},
+		{"unknown cipher", func(kf *keyFile) { kf.Crypto.Cipher = "aes-128-cbc" }, nil},
+	}
+	for _, test := range tests {
+		var kf keyFile
+		if err := json.Unmarshal(content, &kf); err != nil {
+			t.Fatal(err)
+		}
+		test.tamper(&kf)
+		tampered, _ := json.Marshal(kf)
+		if err := ioutil.WriteFile(path, tampered, 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ks.ECDSAKey("alice", "secret")
+		if err == nil || (test.err != nil && !errors.Is(err, test.err)) {
+			t.Errorf("%v: error %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+// flipHex changes the first hex digit of s
+func flipHex(s string) string {
+	if s[0] == '0' {
+		return "1" + s[1:]
+	}
+	return "0" + s[1:]
+}
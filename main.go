@@ -1,29 +1,40 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
-	"net/http"
-	"os"
+	"os/signal"
+	"syscall"
 
 	"./p3"
 )
 
 func main() {
-	if len(os.Args) != 3 && len(os.Args) != 4 {
-		fmt.Println("Usage: go run main.go <port> <id> <firstnode_host(optional)>")
+	configFile := flag.String("config", "", "json configuration file, every setting can be overridden by a "+p3.ENV_PREFIX+"_<SECTION>_<FIELD> environment variable")
+	flag.Parse()
+	if flag.NArg() != 0 {
+		fmt.Println("Usage: go run main.go [-config <json>]")
 		return
 	}
-	nodePort := os.Args[1]
-	nodeID := os.Args[2]
-	var firstNodeHost string
-	if len(os.Args) == 4 {
-		firstNodeHost = os.Args[3]
+	config, err := p3.LoadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	node, err := p3.NewNode(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := node.Start(ctx); err != nil {
+		log.Fatal(err)
+	}
+	<-ctx.Done()
+	fmt.Println("Shutting down...")
+	if err := node.Stop(); err != nil {
+		log.Fatal(err)
 	}
-	p3.FIRST_NODE_HOST = "http://" + firstNodeHost
-	p3.PORT = nodePort
-	p3.NODEID = nodeID
-	router := p3.NewRouter()
-	fmt.Printf("Starting server on port: %v, id: %v\n", nodePort, nodeID)
-	log.Fatal(http.ListenAndServe(":"+nodePort, router))
 }
@@ -6,8 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"sort"
-	"strconv"
 
+	"../api"
+	"../codec"
 	"../p1"
 	"../transaction"
 	"golang.org/x/crypto/sha3"
@@ -19,6 +20,7 @@ type Block struct {
 }
 
 type BlockHeader struct {
+	Version    int32
 	Nonce      string
 	Height     int32
 	Timestamp  int64
@@ -26,17 +28,7 @@ type BlockHeader struct {
 	ParentHash string
 	Size       int32
 	Producer   string
-}
-
-type BlockJsonFormat struct {
-	Nonce      string            `json:"nonce"`
-	Height     int32             `json:"height"`
-	Timestamp  int64             `json:"timestamp"`
-	Hash       string            `json:"hash"`
-	ParentHash string            `json:"parentHash"`
-	Size       int32             `json:"size"`
-	Producer   string            `json:"producer"`
-	Value      map[string]string `json:"value"`
+	Signature  string
 }
 
 type BlockChain struct {
@@ -44,37 +36,164 @@ type BlockChain struct {
 	Length int32
 }
 
-func (block *Block) Initial(height int32, timestamp int64, parentHash string, producer string, value p1.MerklePatriciaTrie) {
+func (block *Block) Initial(version int32, height int32, timestamp int64, parentHash string, producer string, value p1.MerklePatriciaTrie) {
 	header := new(BlockHeader)
+	header.Version = version
 	header.Height = height
 	header.Timestamp = timestamp
 	header.ParentHash = parentHash
 	header.Producer = producer
-	bytes, _ := json.Marshal(value)
-	header.Size = int32(len(bytes))
+	header.Size = int32(len(encodeValue(value)))
 	block.Header = *header
 	block.Value = value
-	hashStr := strconv.Itoa(int(block.Header.Height)) + strconv.Itoa(int(block.Header.Timestamp)) + block.Header.ParentHash + block.Value.Root + strconv.Itoa(int(block.Header.Size))
-	sum := sha3.Sum256([]byte(hashStr))
-	block.Header.Hash = hex.EncodeToString(sum[:])
+	block.Header.Hash = block.GenHash()
+}
+
+// GenHash computes the hash of the block from the canonical encoding of its header fields,
+// the nonce and the signature are not covered since they seal the hash
+func (block *Block) GenHash() string {
+	w := codec.NewWriter(codec.KIND_BLOCK_HEADER)
+	w.Int32(block.Header.Version)
+	w.Int32(block.Header.Height)
+	w.Int64(block.Header.Timestamp)
+	w.String(block.Header.ParentHash)
+	w.String(block.Value.Root)
+	w.Int32(block.Header.Size)
+	w.String(block.Header.Producer)
+	sum := sha3.Sum256(w.Encoded())
+	return hex.EncodeToString(sum[:])
+}
+
+// Transactions decodes the transactions of the block ordered by hash, it fails if one is malformed
+// or not stored under its hash
+func (block *Block) Transactions() ([]tx.Transaction, error) {
+	keys := make([]string, 0, len(block.Value.Mapping))
+	for k := range block.Value.Mapping {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	transactions := make([]tx.Transaction, 0, len(keys))
+	for _, k := range keys {
+		t := new(tx.Transaction)
+		if err := t.Decode([]byte(block.Value.Mapping[k])); err != nil {
+			return nil, fmt.Errorf("transaction %v: %v", k, err)
+		}
+		if t.Hash != k {
+			return nil, fmt.Errorf("transaction %v stored under %v", t.Hash, k)
+		}
+		transactions = append(transactions, *t)
+	}
+	return transactions, nil
+}
+
+// Verify checks the format of the block by the rules of its version and returns its transactions
+func (block *Block) Verify() ([]tx.Transaction, error) {
+	switch block.Header.Version {
+	case BLOCK_VERSION_1:
+		if block.Header.Hash != block.GenHash() {
+			return nil, errors.New("hash does not match the header")
+		}
+		if block.Header.Size != int32(len(encodeValue(block.Value))) {
+			return nil, errors.New("size does not match the transactions")
+		}
+		return block.Transactions()
+	}
+	return nil, fmt.Errorf("unknown block version %d", block.Header.Version)
+}
+
+// Encode returns the canonical binary encoding of the block, used for storage and transfer between nodes
+func (block *Block) Encode() []byte {
+	w := codec.NewWriter(codec.KIND_BLOCK)
+	w.Int32(block.Header.Version)
+	w.Int32(block.Header.Height)
+	w.Int64(block.Header.Timestamp)
+	w.String(block.Header.ParentHash)
+	w.Int32(block.Header.Size)
+	w.String(block.Header.Producer)
+	w.String(block.Header.Hash)
+	w.String(block.Header.Nonce)
+	w.String(block.Header.Signature)
+	writeValue(w, block.Value)
+	return w.Encoded()
+}
+
+// Decode reads a block written by Encode, the transactions are inserted into a new trie
+func (block *Block) Decode(data []byte) error {
+	r := codec.NewReader(data, codec.KIND_BLOCK)
+	header := BlockHeader{
+		Version:    r.Int32(),
+		Height:     r.Int32(),
+		Timestamp:  r.Int64(),
+		ParentHash: r.String(),
+		Size:       r.Int32(),
+		Producer:   r.String(),
+		Hash:       r.String(),
+		Nonce:      r.String(),
+		Signature:  r.String(),
+	}
+	mpt := new(p1.MerklePatriciaTrie)
+	mpt.Initial()
+	n := r.Len()
+	for i := 0; i < n && r.Err() == nil; i++ {
+		k := r.String()
+		v := r.String()
+		mpt.Insert(k, v)
+	}
+	if err := r.Close(); err != nil {
+		return err
+	}
+	block.Header = header
+	block.Value = *mpt
+	return nil
+}
+
+// encodeValue returns the encoding of the trie entries ordered by key, the block size is its length
+func encodeValue(value p1.MerklePatriciaTrie) []byte {
+	w := codec.NewWriter(codec.KIND_TRANSACTIONS)
+	writeValue(w, value)
+	return w.Encoded()
+}
+
+func writeValue(w *codec.Writer, value p1.MerklePatriciaTrie) {
+	keys := make([]string, 0, len(value.Mapping))
+	for k := range value.Mapping {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	w.Len(len(keys))
+	for _, k := range keys {
+		w.String(k)
+		w.String(value.Mapping[k])
+	}
 }
 
 func (block *Block) MarshalJSON() ([]byte, error) {
-	return json.Marshal(&BlockJsonFormat{
+	value := make(map[string]tx.Transaction)
+	for k, v := range block.Value.Mapping {
+		t := new(tx.Transaction)
+		t.Decode([]byte(v))
+		value[k] = *t
+	}
+	return json.Marshal(&api.Block{
+		Version:    block.Header.Version,
 		Height:     block.Header.Height,
 		Timestamp:  block.Header.Timestamp,
 		Hash:       block.Header.Hash,
 		ParentHash: block.Header.ParentHash,
 		Size:       block.Header.Size,
-		Value:      block.Value.Mapping,
+		Value:      value,
 		Nonce:      block.Header.Nonce,
 		Producer:   block.Header.Producer,
+		Signature:  block.Header.Signature,
 	})
 }
 
 func (block *Block) UnmarshalJSON(bytes []byte) error {
-	blockJson := new(BlockJsonFormat)
-	json.Unmarshal(bytes, &blockJson)
+	blockJson := new(api.Block)
+	if err := json.Unmarshal(bytes, &blockJson); err != nil {
+		return err
+	}
+	block.Header.Version = blockJson.Version
 	block.Header.Height = blockJson.Height
 	block.Header.ParentHash = blockJson.ParentHash
 	block.Header.Timestamp = blockJson.Timestamp
@@ -82,10 +201,11 @@ func (block *Block) UnmarshalJSON(bytes []byte) error {
 	block.Header.Size = blockJson.Size
 	block.Header.Nonce = blockJson.Nonce
 	block.Header.Producer = blockJson.Producer
+	block.Header.Signature = blockJson.Signature
 	mpt := new(p1.MerklePatriciaTrie)
 	mpt.Initial()
 	for k, v := range blockJson.Value {
-		mpt.Insert(k, v)
+		mpt.Insert(k, string(v.Encode()))
 	}
 	block.Value = *mpt
 	return nil
@@ -146,6 +266,55 @@ func (bc *BlockChain) DecodeFromJSON(jsonString string) {
 	}
 }
 
+// Encode returns the canonical binary encoding of all blocks ordered by height, used to store and download the chain
+func (bc *BlockChain) Encode() []byte {
+	blocks := bc.blocks()
+	w := codec.NewWriter(codec.KIND_BLOCKCHAIN)
+	w.Len(len(blocks))
+	for _, b := range blocks {
+		w.Bytes(b.Encode())
+	}
+	return w.Encoded()
+}
+
+// DecodeBlocks returns the blocks of a chain written by Encode in the order they were written, without checking them
+func DecodeBlocks(data []byte) ([]Block, error) {
+	r := codec.NewReader(data, codec.KIND_BLOCKCHAIN)
+	n := r.Len()
+	blocks := make([]Block, 0, n)
+	for i := 0; i < n && r.Err() == nil; i++ {
+		b := new(Block)
+		if err := b.Decode(r.Bytes()); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, *b)
+	}
+	if err := r.Close(); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// Decode inserts the blocks of a chain written by Encode, nothing is inserted if the encoding is malformed
+func (bc *BlockChain) Decode(data []byte) error {
+	blocks, err := DecodeBlocks(data)
+	if err != nil {
+		return err
+	}
+	for _, b := range blocks {
+		bc.Insert(b)
+	}
+	return nil
+}
+
+func (bc *BlockChain) blocks() []Block {
+	blocks := []Block{}
+	for i := int32(1); i <= bc.Length; i++ {
+		blocks = append(blocks, bc.Chain[i]...)
+	}
+	return blocks
+}
+
 func (bc *BlockChain) MarshalJSON() ([]byte, error) {
 	blockChainJson := []Block{}
 	for i := int32(1); i <= bc.Length; i++ {
@@ -157,6 +326,18 @@ func (bc *BlockChain) MarshalJSON() ([]byte, error) {
 	return json.Marshal(blockChainJson)
 }
 func (bc *BlockChain) Show() string {
+	rs := bc.showBlocks()
+	sum := sha3.Sum256([]byte(rs))
+	return fmt.Sprintf("This is the BlockChain: %s\n", hex.EncodeToString(sum[:])) + rs
+}
+
+// ShowHash returns the hash printed by Show, chains holding the same blocks have the same hash
+func (bc *BlockChain) ShowHash() string {
+	sum := sha3.Sum256([]byte(bc.showBlocks()))
+	return hex.EncodeToString(sum[:])
+}
+
+func (bc *BlockChain) showBlocks() string {
 	rs := ""
 	var idList []int
 	for id := range bc.Chain {
@@ -175,8 +356,6 @@ func (bc *BlockChain) Show() string {
 		}
 		rs += "\n"
 	}
-	sum := sha3.Sum256([]byte(rs))
-	rs = fmt.Sprintf("This is the BlockChain: %s\n", hex.EncodeToString(sum[:])) + rs
 	return rs
 }
 
@@ -237,29 +416,26 @@ func (bc *BlockChain) Canonicals() ([][]Block, error) {
 }
 
 func (bc *BlockChain) canonical(height int32, lookback int, hash string) ([]Block, error) {
-	latestBlocks, err := bc.GetLatestBlocks()
-	if err != nil {
-		return make([]Block, 0), err
+	blocks := bc.Get(height)
+	if len(blocks) == 0 {
+		return make([]Block, 0), errors.New("empty block chain")
 	}
 
-	if len(latestBlocks) > 1 && hash == "" {
+	if len(blocks) > 1 && hash == "" {
 		return bc.canonical(height-1, lookback, "")
 	}
 
-	if lookback == 0 {
-		var block Block
-		if hash == "" {
-			block = latestBlocks[0]
-		} else {
-			for _, b := range latestBlocks {
-				if b.Header.Hash == hash {
-					block = b
-				}
+	block := blocks[0]
+	if hash != "" {
+		for _, b := range blocks {
+			if b.Header.Hash == hash {
+				block = b
 			}
 		}
+	}
+	if lookback == 0 {
 		return bc.CanonicalFromBlock(block), nil
 	}
-	block := latestBlocks[0]
 	return bc.canonical(height-1, lookback-1, block.Header.ParentHash)
 }
 
@@ -276,14 +452,24 @@ func (bc *BlockChain) CanonicalFromBlock(b Block) []Block {
 
 func (bc *BlockChain) Transactions() []tx.Transaction {
 	canonicalchain, _ := bc.Canonical(0)
+	return blockTransactions(canonicalchain)
+}
+
+// AncestorTransactions returns the transactions of the chain the block extends, the block itself excluded
+func (bc *BlockChain) AncestorTransactions(block Block) []tx.Transaction {
+	parent, err := bc.GetParentBlock(block)
+	if err != nil {
+		return make([]tx.Transaction, 0)
+	}
+	return blockTransactions(bc.CanonicalFromBlock(parent))
+}
+
+func blockTransactions(blocks []Block) []tx.Transaction {
 	transactions := make([]tx.Transaction, 0)
-	for _, b := range canonicalchain {
-		for k := range b.Value.Mapping {
-			v, _ := b.Value.Get(k)
-			t := new(tx.Transaction)
-			t.DecodeFromJSON(v)
-			transactions = append(transactions, *t)
-		}
+	for _, b := range blocks {
+		//The blocks were verified before they were inserted
+		txs, _ := b.Transactions()
+		transactions = append(transactions, txs...)
 	}
 	return transactions
 }
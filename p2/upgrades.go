@@ -0,0 +1,100 @@
+package p2
+
+import (
+	"errors"
+	"fmt"
+
+	"../transaction"
+)
+
+// Block versions, every version keeps its own validation rules so that blocks mined before an upgrade stay valid
+const (
+	BLOCK_VERSION_1       = 1
+	CURRENT_BLOCK_VERSION = BLOCK_VERSION_1
+)
+
+// Upgrade activates a block version, a maximal transaction version and protocol features from Height on.
+// Blocks at or above Height must carry BlockVersion, transactions up to TxVersion are accepted in them
+type Upgrade struct {
+	Name         string   `json:"name"`
+	Height       int32    `json:"height"`
+	BlockVersion int32    `json:"blockVersion"`
+	TxVersion    int32    `json:"txVersion"`
+	Features     []string `json:"features,omitempty"`
+}
+
+// Schedule lists the upgrades of the network ordered by activation height, all nodes of a network have to share it
+type Schedule []Upgrade
+
+// DefaultSchedule starts the chain with the first block and transaction versions
+func DefaultSchedule() Schedule {
+	return Schedule{{Name: "genesis", Height: 1, BlockVersion: BLOCK_VERSION_1, TxVersion: tx.VERSION_1}}
+}
+
+// At returns the latest upgrade active at height
+func (schedule Schedule) At(height int32) Upgrade {
+	active := schedule[0]
+	for _, upgrade := range schedule[1:] {
+		if upgrade.Height > height {
+			break
+		}
+		active = upgrade
+	}
+	return active
+}
+
+// Active returns true if the feature was activated at or below height, features stay active once activated
+func (schedule Schedule) Active(feature string, height int32) bool {
+	for _, upgrade := range schedule {
+		if upgrade.Height > height {
+			break
+		}
+		for _, f := range upgrade.Features {
+			if f == feature {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Validate checks that the schedule starts at the first block, activates known versions only and never downgrades
+func (schedule Schedule) Validate() error {
+	if len(schedule) == 0 || schedule[0].Height != 1 {
+		return errors.New("the first upgrade must activate at height 1")
+	}
+	for i, upgrade := range schedule {
+		if upgrade.BlockVersion < 1 || upgrade.BlockVersion > CURRENT_BLOCK_VERSION {
+			return fmt.Errorf("upgrade %q activates unknown block version %d", upgrade.Name, upgrade.BlockVersion)
+		}
+		if upgrade.TxVersion < 1 || upgrade.TxVersion > tx.CURRENT_VERSION {
+			return fmt.Errorf("upgrade %q activates unknown transaction version %d", upgrade.Name, upgrade.TxVersion)
+		}
+		if i == 0 {
+			continue
+		}
+		previous := schedule[i-1]
+		if upgrade.Height <= previous.Height {
+			return fmt.Errorf("upgrade %q must activate above height %d", upgrade.Name, previous.Height)
+		}
+		if upgrade.BlockVersion < previous.BlockVersion || upgrade.TxVersion < previous.TxVersion {
+			return fmt.Errorf("upgrade %q downgrades the versions of %q", upgrade.Name, previous.Name)
+		}
+	}
+	return nil
+}
+
+// VerifyVersions returns an error if the block or one of its transactions carries a version the schedule does not
+// allow at the height of the block
+func (schedule Schedule) VerifyVersions(block Block, txs []tx.Transaction) error {
+	upgrade := schedule.At(block.Header.Height)
+	if block.Header.Version != upgrade.BlockVersion {
+		return fmt.Errorf("block version %d, upgrade %q requires %d", block.Header.Version, upgrade.Name, upgrade.BlockVersion)
+	}
+	for _, t := range txs {
+		if t.Version < 1 || t.Version > upgrade.TxVersion {
+			return fmt.Errorf("transaction %v has version %d, upgrade %q allows up to %d", t.Hash, t.Version, upgrade.Name, upgrade.TxVersion)
+		}
+	}
+	return nil
+}
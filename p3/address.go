@@ -0,0 +1,89 @@
+package p3
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// OBSERVED_ADDR_HEADER carries the address a node was seen from back to it in the handshake response
+const OBSERVED_ADDR_HEADER = "X-Observed-Addr"
+
+// ADDR_QUORUM is the number of distinct peers that have to observe the same address before a node advertises it.
+// A node with fewer peers keeps its configured or bind address
+const ADDR_QUORUM = 2
+
+// getSelfAddr returns the address this node advertises to its peers
+func (node *Node) getSelfAddr() string {
+	node.addrMux.Lock()
+	defer node.addrMux.Unlock()
+	return node.selfAddr
+}
+
+// initSelfAddr sets the advertised address from the configuration, falling back to the bind address or localhost
+func (node *Node) initSelfAddr() {
+	node.addrMux.Lock()
+	defer node.addrMux.Unlock()
+	node.addrLearned = false
+	node.observations = make(map[string]map[string]bool)
+	if node.config.Network.AdvertiseAddr != "" {
+		node.selfAddr = NormalizeAddr(node.config.Network.AdvertiseAddr)
+		return
+	}
+	host := node.config.API.Bind
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	node.selfAddr = "http://" + net.JoinHostPort(host, node.config.API.Port)
+}
+
+// setObservedAddr tells a peer the address its request came from in the response header
+func setObservedAddr(w http.ResponseWriter, r *http.Request, advertised string) {
+	if observed := observedAddr(r.RemoteAddr, advertised); observed != "" {
+		w.Header().Set(OBSERVED_ADDR_HEADER, observed)
+	}
+}
+
+// observedAddr returns the address a peer connected from, on the port the peer advertises
+func observedAddr(remoteAddr string, advertised string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return ""
+	}
+	u, err := url.Parse(advertised)
+	if err != nil || u.Port() == "" {
+		return ""
+	}
+	return u.Scheme + "://" + net.JoinHostPort(host, u.Port())
+}
+
+// learnAddr records the address a peer observed this node at. A node without a configured advertised address
+// adopts an observed address once ADDR_QUORUM peers agree on it, so that a single peer cannot redirect it
+func (node *Node) learnAddr(observed string, peer string) {
+	if observed == "" || node.config.Network.AdvertiseAddr != "" {
+		return
+	}
+	u, err := url.Parse(observed)
+	if err != nil || u.Hostname() == "" {
+		return
+	}
+	if ip := net.ParseIP(u.Hostname()); ip == nil || ip.IsLoopback() || ip.IsUnspecified() {
+		return
+	}
+
+	node.addrMux.Lock()
+	defer node.addrMux.Unlock()
+	if node.addrLearned || observed == node.selfAddr {
+		return
+	}
+	if node.observations[observed] == nil {
+		node.observations[observed] = make(map[string]bool)
+	}
+	node.observations[observed][peer] = true
+	if len(node.observations[observed]) >= ADDR_QUORUM {
+		fmt.Printf("Peers observed this node at %v, advertising it instead of %v\n", observed, node.selfAddr)
+		node.selfAddr = observed
+		node.addrLearned = true
+	}
+}
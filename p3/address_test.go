@@ -0,0 +1,25 @@
+package p3
+
+import "testing"
+
+func TestLearnAddrNeedsQuorum(t *testing.T) {
+	node := &Node{config: DefaultConfig()}
+	node.initSelfAddr()
+	configured := node.getSelfAddr()
+
+	//A single peer repeating itself cannot redirect the node
+	node.learnAddr("http://203.0.113.7:6686", "http://198.51.100.1:6686")
+	node.learnAddr("http://203.0.113.7:6686", "http://198.51.100.1:6686")
+	if addr := node.getSelfAddr(); addr != configured {
+		t.Fatalf("advertising %v after the reports of one peer, want %v", addr, configured)
+	}
+	//Disagreeing peers do not make a quorum either
+	node.learnAddr("http://203.0.113.8:6686", "http://198.51.100.2:6686")
+	if addr := node.getSelfAddr(); addr != configured {
+		t.Fatalf("advertising %v after disagreeing reports, want %v", addr, configured)
+	}
+	node.learnAddr("http://203.0.113.7:6686", "http://198.51.100.3:6686")
+	if addr := node.getSelfAddr(); addr != "http://203.0.113.7:6686" {
+		t.Fatalf("advertising %v after %d agreeing peers", addr, ADDR_QUORUM)
+	}
+}
@@ -0,0 +1,87 @@
+package p3
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Bootstrap downloads the blockchain from the first reachable seed or persisted peer and then starts mining,
+// it keeps retrying in the background with backoff while no seed is reachable
+func (node *Node) Bootstrap() {
+	candidates := node.bootstrapCandidates()
+	if len(candidates) == 0 {
+		fmt.Println("No seeds configured, starting a new network")
+		node.bootstrapped.Store(true)
+		node.goroutine(node.StartTryingNonces)
+		return
+	}
+	retry := time.Duration(node.config.Network.RetryBackoff)
+	for true {
+		for _, seed := range candidates {
+			if err := node.Download(seed); err != nil {
+				fmt.Printf("Cannot download from %v: %v\n", seed, err)
+				continue
+			}
+			fmt.Printf("Downloaded blockchain from %v\n", seed)
+			node.bootstrapped.Store(true)
+			node.goroutine(node.StartTryingNonces)
+			return
+		}
+		fmt.Printf("No seed reachable, retrying in %v\n", retry)
+		if !node.sleep(retry) {
+			return
+		}
+		if retry < 2*time.Minute {
+			retry *= 2
+		}
+		candidates = node.bootstrapCandidates()
+	}
+}
+
+// bootstrapCandidates returns the configured seeds followed by the known peers
+func (node *Node) bootstrapCandidates() []string {
+	candidates := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, seed := range node.config.Network.Seeds {
+		seed = NormalizeAddr(seed)
+		if seed != node.getSelfAddr() && !seen[seed] {
+			seen[seed] = true
+			candidates = append(candidates, seed)
+		}
+	}
+	for addr := range node.peers.Copy() {
+		if !seen[addr] {
+			seen[addr] = true
+			candidates = append(candidates, addr)
+		}
+	}
+	return candidates
+}
+
+// NormalizeAddr prefixes host:port addresses with the http scheme
+func NormalizeAddr(addr string) string {
+	addr = strings.TrimSuffix(strings.TrimSpace(addr), "/")
+	if !strings.HasPrefix(addr, "http://") && !strings.HasPrefix(addr, "https://") {
+		addr = "http://" + addr
+	}
+	return addr
+}
+
+// loadPeers adds the peers persisted by a previous run to the peer list
+func (node *Node) loadPeers() {
+	bytes, ok := node.readStorage(node.config.Storage.PeersFile)
+	if !ok {
+		return
+	}
+	node.peers.InjectPeerMapJson(string(bytes), node.getSelfAddr())
+}
+
+// savePeers persists the peer list for the next start
+func (node *Node) savePeers() {
+	peerMapJSON, err := node.peers.PeerMapToJson()
+	if err != nil {
+		return
+	}
+	node.writeStorage(node.config.Storage.PeersFile, []byte(peerMapJSON))
+}
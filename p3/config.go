@@ -0,0 +1,302 @@
+package p3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"../p2"
+)
+
+// ENV_PREFIX prefixes the environment variables overriding the configuration, e.g. JOBMARKET_API_PORT
+const ENV_PREFIX = "JOBMARKET"
+
+// Config is the typed node configuration, loaded from a json file with environment variable overrides
+type Config struct {
+	Network NetworkConfig `json:"network"`
+	Mining  MiningConfig  `json:"mining"`
+	Mempool MempoolConfig `json:"mempool"`
+	Storage StorageConfig `json:"storage"`
+	API     APIConfig     `json:"api"`
+}
+
+// NetworkConfig holds the peer-to-peer settings, AdvertiseAddr is the address peers should use to reach this node,
+// it is learned from the peers when empty. Transport is "http" for one json request per message or "websocket" for
+// one persistent connection per peer, nodes accept both regardless of the transport they use. Heartbeats signed
+// more than HeartBeatMaxAge ago are refused as replays
+type NetworkConfig struct {
+	AdvertiseAddr     string   `json:"advertiseAddr"`
+	Transport         string   `json:"transport"`
+	Seeds             []string `json:"seeds"`
+	MaxPeers          int32    `json:"maxPeers"`
+	HeartBeatInterval Duration `json:"heartbeatInterval"`
+	HeartBeatMaxAge   Duration `json:"heartbeatMaxAge"`
+	PeerTimeout       Duration `json:"peerTimeout"`
+	MaxFailures       int32    `json:"maxFailures"`
+	RetryBackoff      Duration `json:"retryBackoff"`
+	BanThreshold      int32    `json:"banThreshold"`
+	BanDuration       Duration `json:"banDuration"`
+	SeenTTL           Duration `json:"seenTTL"`
+}
+
+// MiningConfig holds the block production settings, Upgrades is the schedule activating block and transaction
+// versions by height and has to be the same on all nodes of a network
+type MiningConfig struct {
+	Consensus     string      `json:"consensus"`
+	Difficulty    string      `json:"difficulty"`
+	Validators    []string    `json:"validators"`
+	BlockSize     int         `json:"blockSize"`
+	FinalityDepth int32       `json:"finalityDepth"`
+	MinerKeyFile  string      `json:"minerKeyFile"`
+	PayoutKeyFile string      `json:"payoutKeyFile"`
+	Upgrades      p2.Schedule `json:"upgrades"`
+}
+
+type MempoolConfig struct {
+	MaxSize   int      `json:"maxSize"`
+	MinFee    float32  `json:"minFee"`
+	EmptyWait Duration `json:"emptyWait"`
+}
+
+// StorageConfig holds the files of the node, relative paths are resolved against DataDir
+type StorageConfig struct {
+	DataDir     string `json:"dataDir"`
+	ChainFile   string `json:"chainFile"`
+	MempoolFile string `json:"mempoolFile"`
+	PeersFile   string `json:"peersFile"`
+	BanFile     string `json:"banFile"`
+}
+
+// APIConfig holds the listener settings, an empty Bind listens on all interfaces.
+// ShutdownTimeout bounds how long in-flight requests are drained when the node stops
+type APIConfig struct {
+	Bind            string   `json:"bind"`
+	Port            string   `json:"port"`
+	ShutdownTimeout Duration `json:"shutdownTimeout"`
+}
+
+// Duration is a time.Duration written as a string like "5s" in the configuration
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(bytes []byte) error {
+	var str string
+	if err := json.Unmarshal(bytes, &str); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(str)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Network: NetworkConfig{
+			Transport:         "http",
+			Seeds:             []string{},
+			MaxPeers:          32,
+			HeartBeatInterval: Duration(5 * time.Second),
+			HeartBeatMaxAge:   Duration(5 * time.Minute),
+			PeerTimeout:       Duration(5 * time.Second),
+			MaxFailures:       5,
+			RetryBackoff:      Duration(5 * time.Second),
+			BanThreshold:      -100,
+			BanDuration:       Duration(time.Hour),
+			SeenTTL:           Duration(10 * time.Minute),
+		},
+		Mining: MiningConfig{
+			Consensus:     "pow",
+			Difficulty:    "000000",
+			Validators:    []string{},
+			BlockSize:     20,
+			FinalityDepth: 6,
+			MinerKeyFile:  "miner.pem",
+			Upgrades:      p2.DefaultSchedule(),
+		},
+		Mempool: MempoolConfig{
+			MaxSize:   10000,
+			MinFee:    0,
+			EmptyWait: Duration(7 * time.Second),
+		},
+		//The miner key, the chain, the mempool and the peers survive a restart
+		Storage: StorageConfig{
+			DataDir:     "data",
+			ChainFile:   "chain.bin",
+			MempoolFile: "mempool.bin",
+			PeersFile:   "peers.json",
+			BanFile:     "bans.json",
+		},
+		API: APIConfig{
+			Port:            "6686",
+			ShutdownTimeout: Duration(10 * time.Second),
+		},
+	}
+}
+
+// LoadConfig reads the configuration file on top of the defaults, applies the environment overrides and validates the result,
+// an empty filename only uses the defaults and the environment
+func LoadConfig(filename string) (Config, error) {
+	config := DefaultConfig()
+	if filename != "" {
+		bytes, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return config, err
+		}
+		if err := json.Unmarshal(bytes, &config); err != nil {
+			return config, fmt.Errorf("cannot parse %v: %v", filename, err)
+		}
+	}
+	if err := config.applyEnv(os.LookupEnv); err != nil {
+		return config, err
+	}
+	return config, config.Validate()
+}
+
+// applyEnv overrides every field that has an environment variable named ENV_PREFIX_<SECTION>_<FIELD>
+func (config *Config) applyEnv(lookup func(string) (string, bool)) error {
+	sections := reflect.ValueOf(config).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+		sectionName := jsonName(sections.Type().Field(i))
+		for j := 0; j < section.NumField(); j++ {
+			name := ENV_PREFIX + "_" + sectionName + "_" + jsonName(section.Type().Field(j))
+			value, ok := lookup(name)
+			if !ok {
+				continue
+			}
+			if err := setField(section.Field(j), value); err != nil {
+				return fmt.Errorf("invalid %v: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func jsonName(field reflect.StructField) string {
+	return strings.ToUpper(strings.Split(field.Tag.Get("json"), ",")[0])
+}
+
+func setField(field reflect.Value, value string) error {
+	switch field.Interface().(type) {
+	case Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(Duration(d)))
+	case string:
+		field.SetString(value)
+	case []string:
+		list := make([]string, 0)
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				list = append(list, v)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	case int, int32:
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case float32:
+		f, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return errors.New("unsupported type")
+	}
+	return nil
+}
+
+// Validate checks that the configuration can be used to run a node
+func (config *Config) Validate() error {
+	port, err := strconv.Atoi(config.API.Port)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("api.port %q is not a valid port", config.API.Port)
+	}
+	if config.API.ShutdownTimeout <= 0 {
+		return errors.New("api.shutdownTimeout must be positive")
+	}
+	if config.API.Bind != "" && net.ParseIP(config.API.Bind) == nil {
+		return fmt.Errorf("api.bind %q is not an ip address", config.API.Bind)
+	}
+	if config.Network.AdvertiseAddr != "" {
+		u, err := url.Parse(NormalizeAddr(config.Network.AdvertiseAddr))
+		if err != nil || u.Hostname() == "" || u.Port() == "" {
+			return fmt.Errorf("network.advertiseAddr %q must be a host and port", config.Network.AdvertiseAddr)
+		}
+	}
+	if config.Network.Transport != "http" && config.Network.Transport != "websocket" {
+		return fmt.Errorf("unknown network.transport %q", config.Network.Transport)
+	}
+	if config.Network.MaxPeers < 2 {
+		return errors.New("network.maxPeers must be at least 2")
+	}
+	if config.Network.HeartBeatInterval <= 0 || config.Network.HeartBeatMaxAge <= 0 || config.Network.PeerTimeout <= 0 || config.Network.RetryBackoff <= 0 || config.Network.SeenTTL <= 0 {
+		return errors.New("network intervals and timeouts must be positive")
+	}
+	if config.Network.MaxFailures < 1 {
+		return errors.New("network.maxFailures must be at least 1")
+	}
+	if config.Network.BanThreshold >= 0 {
+		return errors.New("network.banThreshold must be negative")
+	}
+	switch config.Mining.Consensus {
+	case "pow":
+		if strings.Trim(config.Mining.Difficulty, "0123456789abcdef") != "" {
+			return fmt.Errorf("mining.difficulty %q is not a hex prefix", config.Mining.Difficulty)
+		}
+	case "poa":
+		if len(config.Mining.Validators) == 0 {
+			return errors.New("mining.validators must not be empty for poa")
+		}
+	default:
+		return fmt.Errorf("unknown mining.consensus %q", config.Mining.Consensus)
+	}
+	if config.Mining.BlockSize < 1 {
+		return errors.New("mining.blockSize must be at least 1")
+	}
+	if config.Mining.FinalityDepth < 0 {
+		return errors.New("mining.finalityDepth must not be negative")
+	}
+	if err := config.Mining.Upgrades.Validate(); err != nil {
+		return fmt.Errorf("mining.upgrades: %v", err)
+	}
+	if config.Mempool.MaxSize < 1 {
+		return errors.New("mempool.maxSize must be at least 1")
+	}
+	if config.Mempool.MinFee < 0 {
+		return errors.New("mempool.minFee must not be negative")
+	}
+	if config.Mempool.EmptyWait <= 0 {
+		return errors.New("mempool.emptyWait must be positive")
+	}
+	return nil
+}
+
+// path resolves a storage file against the data directory, an empty name stays empty
+func (config *Config) path(name string) string {
+	if name == "" || filepath.IsAbs(name) || config.Storage.DataDir == "" {
+		return name
+	}
+	return filepath.Join(config.Storage.DataDir, name)
+}
@@ -0,0 +1,259 @@
+package p3
+
+import (
+	"crypto/ecdsa"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"../codec"
+	"../p2"
+	"../transaction"
+	"golang.org/x/crypto/sha3"
+)
+
+// Consensus decides how blocks are sealed, which seals are valid and which fork to build on
+type Consensus interface {
+	// Seal tries to seal the candidate block, returns false if the block was not sealed in this round
+	Seal(block *p2.Block) bool
+	// VerifySeal returns true if the block carries a valid seal
+	VerifySeal(block p2.Block) bool
+	// SelectTip picks the block to build on among the latest blocks of the chain
+	SelectTip(tips []p2.Block) p2.Block
+}
+
+// BlockReader looks up the blocks of the chain a seal is checked against
+type BlockReader interface {
+	GetBlock(height int32, hash string) (p2.Block, bool)
+}
+
+func (node *Node) newConsensus() (Consensus, error) {
+	switch node.config.Mining.Consensus {
+	case "pow":
+		return &ProofOfWork{Difficulty: node.config.Mining.Difficulty}, nil
+	case "poa":
+		return NewProofOfAuthority(node.config.Mining.Validators, node.minerKey, &node.sbc)
+	}
+	return nil, fmt.Errorf("unknown consensus %v", node.config.Mining.Consensus)
+}
+
+// ProofOfWork seals a block by finding a nonce whose hash starts with Difficulty
+type ProofOfWork struct {
+	Difficulty string
+}
+
+// Seal tries one random nonce on the block
+func (pow *ProofOfWork) Seal(block *p2.Block) bool {
+	block.Header.Nonce = genHex(16)
+	return pow.VerifySeal(*block)
+}
+
+// VerifySeal checks that the hash covers the header and that the seal hash meets the difficulty
+func (pow *ProofOfWork) VerifySeal(block p2.Block) bool {
+	if block.Header.Hash != block.GenHash() {
+		return false
+	}
+	return strings.HasPrefix(sealHash(block), pow.Difficulty)
+}
+
+// sealHash commits to the block hash and the nonce, the hash in turn commits to the header and the transactions
+func sealHash(block p2.Block) string {
+	w := codec.NewWriter(codec.KIND_SEAL)
+	w.String(block.Header.Hash)
+	w.String(block.Header.Nonce)
+	sum := sha3.Sum256(w.Encoded())
+	return hex.EncodeToString(sum[:])
+}
+
+// SelectTip picks the lowest hash so that miners seeing the same forks extend the same one
+func (pow *ProofOfWork) SelectTip(tips []p2.Block) p2.Block {
+	return lowestHash(tips)
+}
+
+// ProofOfAuthority lets a fixed set of validators sign blocks in round-robin order. A validator that is not in turn
+// may sign a block whose timestamp is OutOfTurnDelay after its parent for every validator between the one in turn
+// and itself, so that offline validators do not halt the chain. Blocks signed in turn win the fork choice over blocks
+// signed out of turn. No validator may sign one of the last len(Validators)/2 blocks before the block, so the chain
+// goes on as long as more than half of the validators are online and no validator can take the rotation over
+type ProofOfAuthority struct {
+	Validators     []string
+	Period         time.Duration
+	OutOfTurnDelay time.Duration
+	signer         *ecdsa.PrivateKey
+	self           string
+	chain          BlockReader
+}
+
+func NewProofOfAuthority(validators []string, signer *ecdsa.PrivateKey, chain BlockReader) (*ProofOfAuthority, error) {
+	if len(validators) == 0 {
+		return nil, errors.New("empty validator set")
+	}
+	poa := &ProofOfAuthority{Validators: validators, Period: time.Second, OutOfTurnDelay: 5 * time.Second, signer: signer, chain: chain}
+	poa.self = tx.EncodeECDSAPublicKey(&signer.PublicKey)
+	found := false
+	for _, v := range validators {
+		if _, err := tx.DecodeECDSAPublicKey(v); err != nil {
+			return nil, fmt.Errorf("validator %q: %v", v, err)
+		}
+		if v == poa.self {
+			found = true
+		}
+	}
+	if !found {
+		fmt.Println("This node is not a validator, it will only follow the chain")
+	}
+	return poa, nil
+}
+
+// inTurn returns the validator allowed to sign the block at height without waiting
+func (poa *ProofOfAuthority) inTurn(height int32) string {
+	return poa.Validators[poa.turn(height, 0)]
+}
+
+// turn returns the index of the validator that comes distance validators after the one in turn at height
+func (poa *ProofOfAuthority) turn(height int32, distance int) int {
+	n := int32(len(poa.Validators))
+	return int(((height-1+int32(distance))%n + n) % n)
+}
+
+// distance returns how many validators come before the validator at height, 0 if it is in turn and -1 if it is
+// not a validator
+func (poa *ProofOfAuthority) distance(height int32, validator string) int {
+	for d := range poa.Validators {
+		if poa.Validators[poa.turn(height, d)] == validator {
+			return d
+		}
+	}
+	return -1
+}
+
+// parent returns the parent of the block from the chain
+func (poa *ProofOfAuthority) parent(block p2.Block) (p2.Block, bool) {
+	return poa.chain.GetBlock(block.Header.Height-1, block.Header.ParentHash)
+}
+
+// signedRecently returns true if the validator signed one of the last len(Validators)/2 blocks before the block
+func (poa *ProofOfAuthority) signedRecently(block p2.Block, validator string) bool {
+	for i := 0; i < len(poa.Validators)/2; i++ {
+		parent, ok := poa.parent(block)
+		if !ok {
+			return false
+		}
+		if poa.sealer(parent) == validator {
+			return true
+		}
+		block = parent
+	}
+	return false
+}
+
+// earliest returns the earliest timestamp, in milliseconds, the validator at distance may sign the block with.
+// The first block has no parent to wait after, its waiting starts when the candidate block was built
+func (poa *ProofOfAuthority) earliest(block p2.Block, distance int) int64 {
+	start := block.Header.Timestamp
+	if parent, ok := poa.parent(block); ok {
+		start = parent.Header.Timestamp
+	}
+	return start + int64(distance)*int64(poa.OutOfTurnDelay/time.Millisecond)
+}
+
+// Seal signs the block if it is this node's turn or if the validators before this node let the block wait too
+// long, otherwise waits for one period. A block signed out of turn is stamped with the time it is signed at, so
+// that the other nodes see the wait
+func (poa *ProofOfAuthority) Seal(block *p2.Block) bool {
+	distance := poa.distance(block.Header.Height, poa.self)
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	if distance < 0 || poa.signedRecently(*block, poa.self) || now < poa.earliest(*block, distance) {
+		time.Sleep(poa.Period)
+		return false
+	}
+	if distance > 0 && block.Header.Height > 1 {
+		block.Header.Timestamp = now
+		block.Header.Hash = block.GenHash()
+	}
+	hashbytes, err := hex.DecodeString(block.Header.Hash)
+	if err != nil {
+		return false
+	}
+	signature, err := ecdsa.SignASN1(crand.Reader, poa.signer, hashbytes)
+	if err != nil {
+		return false
+	}
+	block.Header.Signature = hex.EncodeToString(signature)
+	return true
+}
+
+// VerifySeal returns true if one of the validators signed the block, the validator did not sign one of the blocks
+// before it too recently and a block signed out of turn waited long enough after its parent. The parent has to be
+// in the chain. A block signed out of turn may not be stamped ahead of our clock by more than one period, it could
+// claim the wait otherwise
+func (poa *ProofOfAuthority) VerifySeal(block p2.Block) bool {
+	validator := poa.sealer(block)
+	if validator == "" || poa.signedRecently(block, validator) {
+		return false
+	}
+	if block.Header.Height == 1 {
+		return true
+	}
+	if _, ok := poa.parent(block); !ok {
+		return false
+	}
+	distance := poa.distance(block.Header.Height, validator)
+	if distance == 0 {
+		return true
+	}
+	ahead := time.Until(time.Unix(0, block.Header.Timestamp*int64(time.Millisecond)))
+	return block.Header.Timestamp >= poa.earliest(block, distance) && ahead <= poa.Period
+}
+
+// sealer returns the validator that signed the block, "" if the seal is invalid. The validators are tried in turn
+// order, so a block signed in turn is checked with one verification
+func (poa *ProofOfAuthority) sealer(block p2.Block) string {
+	if block.Header.Hash != block.GenHash() {
+		return ""
+	}
+	hashbytes, err := hex.DecodeString(block.Header.Hash)
+	if err != nil {
+		return ""
+	}
+	signature, err := hex.DecodeString(block.Header.Signature)
+	if err != nil {
+		return ""
+	}
+	for d := range poa.Validators {
+		validator := poa.Validators[poa.turn(block.Header.Height, d)]
+		key, err := tx.DecodeECDSAPublicKey(validator)
+		if err == nil && ecdsa.VerifyASN1(key, hashbytes, signature) {
+			return validator
+		}
+	}
+	return ""
+}
+
+// SelectTip picks the lowest hash among the blocks signed in turn, or among all blocks if none was, so that all
+// validators build on the same fork and a block signed out of turn is abandoned for one signed in turn
+func (poa *ProofOfAuthority) SelectTip(tips []p2.Block) p2.Block {
+	inTurn := make([]p2.Block, 0, len(tips))
+	for _, b := range tips {
+		if poa.sealer(b) == poa.inTurn(b.Header.Height) {
+			inTurn = append(inTurn, b)
+		}
+	}
+	if len(inTurn) > 0 {
+		return lowestHash(inTurn)
+	}
+	return lowestHash(tips)
+}
+
+func lowestHash(tips []p2.Block) p2.Block {
+	lowest := tips[0]
+	for _, b := range tips[1:] {
+		if b.Header.Hash < lowest.Header.Hash {
+			lowest = b
+		}
+	}
+	return lowest
+}
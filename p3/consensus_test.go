@@ -0,0 +1,114 @@
+package p3
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"../p1"
+	"../p2"
+	"../transaction"
+)
+
+// testChain is a BlockReader over the blocks added to it
+type testChain map[string]p2.Block
+
+func (chain testChain) GetBlock(height int32, hash string) (p2.Block, bool) {
+	block, ok := chain[hash]
+	return block, ok && block.Header.Height == height
+}
+
+// signedBlock returns a block on top of parent stamped with timestamp and signed by key
+func signedBlock(t *testing.T, parent *p2.Block, timestamp int64, key *ecdsa.PrivateKey) p2.Block {
+	mpt := p1.MerklePatriciaTrie{}
+	mpt.Initial()
+	height, parentHash := int32(1), "Genesis"
+	if parent != nil {
+		height, parentHash = parent.Header.Height+1, parent.Header.Hash
+	}
+	var block p2.Block
+	block.Initial(p2.BLOCK_VERSION_1, height, timestamp, parentHash, tx.Address(&key.PublicKey), mpt)
+	block.Header.Hash = block.GenHash()
+	hashbytes, _ := hex.DecodeString(block.Header.Hash)
+	signature, err := ecdsa.SignASN1(crand.Reader, key, hashbytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Header.Signature = hex.EncodeToString(signature)
+	return block
+}
+
+func TestProofOfAuthorityVerifySeal(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	validators := make([]string, 3)
+	for i := range keys {
+		keys[i], _ = ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+		validators[i] = tx.EncodeECDSAPublicKey(&keys[i].PublicKey)
+	}
+	outsider, _ := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	chain := testChain{}
+	poa, err := NewProofOfAuthority(validators, keys[0], chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delay := int64(poa.OutOfTurnDelay / time.Millisecond)
+	start := time.Now().Add(-time.Hour).UnixNano() / int64(time.Millisecond)
+
+	//Heights 1 and 2 are signed in turn by validators 0 and 1
+	first := signedBlock(t, nil, start, keys[0])
+	chain[first.Header.Hash] = first
+	second := signedBlock(t, &first, start+1000, keys[1])
+	chain[second.Header.Hash] = second
+
+	tests := []struct {
+		name  string
+		block p2.Block
+		valid bool
+	}{
+		{"first block in turn", first, true},
+		{"in turn", second, true},
+		{"not a validator", signedBlock(t, &first, start+1000, outsider), false},
+		{"in turn at height 3", signedBlock(t, &second, start+2000, keys[2]), true},
+		{"out of turn after the wait", signedBlock(t, &second, start+1000+delay, keys[0]), true},
+		{"out of turn before the wait", signedBlock(t, &second, start+1000+delay-1, keys[0]), false},
+		{"signer of the parent", signedBlock(t, &second, start+1000+2*delay, keys[1]), false},
+		{"out of turn ahead of the clock", signedBlock(t, &second, time.Now().Add(time.Minute).UnixNano()/int64(time.Millisecond), keys[0]), false},
+	}
+	for _, test := range tests {
+		if valid := poa.VerifySeal(test.block); valid != test.valid {
+			t.Errorf("%v: VerifySeal = %v, want %v", test.name, valid, test.valid)
+		}
+	}
+
+	orphan := signedBlock(t, &second, start+2000, keys[2])
+	orphan.Header.ParentHash = "unknown"
+	orphan.Header.Hash = orphan.GenHash()
+	if poa.VerifySeal(orphan) {
+		t.Error("VerifySeal accepted a block without parent")
+	}
+}
+
+func TestProofOfAuthoritySelectTipPrefersInTurn(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 2)
+	validators := make([]string, 2)
+	for i := range keys {
+		keys[i], _ = ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+		validators[i] = tx.EncodeECDSAPublicKey(&keys[i].PublicKey)
+	}
+	poa, err := NewProofOfAuthority(validators, keys[0], testChain{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := signedBlock(t, nil, 1000, keys[0])
+	//Try both orders of hashes, the block signed in turn has to win either way
+	for i := 0; i < 8; i++ {
+		inTurn := signedBlock(t, &first, int64(2000+i), keys[1])
+		outOfTurn := signedBlock(t, &first, int64(9000+i), keys[0])
+		if tip := poa.SelectTip([]p2.Block{outOfTurn, inTurn}); tip.Header.Hash != inTurn.Header.Hash {
+			t.Fatalf("SelectTip picked the block signed out of turn")
+		}
+	}
+}
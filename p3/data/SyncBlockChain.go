@@ -10,14 +10,18 @@ import (
 )
 
 type SyncBlockChain struct {
-	bc  p2.BlockChain
-	mux sync.Mutex
+	bc              p2.BlockChain
+	mux             sync.Mutex
+	finalityDepth   int32
+	finalizedHeight int32
+	finalizedHash   string
+	schedule        p2.Schedule
 }
 
 func NewBlockChain() SyncBlockChain {
 	blockChain := new(p2.BlockChain)
 	blockChain.Initial()
-	return SyncBlockChain{bc: *blockChain}
+	return SyncBlockChain{bc: *blockChain, schedule: p2.DefaultSchedule()}
 }
 
 func (sbc *SyncBlockChain) Get(height int32) ([]p2.Block, bool) {
@@ -43,10 +47,95 @@ func (sbc *SyncBlockChain) GetBlock(height int32, hash string) (p2.Block, bool)
 	return *output, false
 }
 
-func (sbc *SyncBlockChain) Insert(block p2.Block) {
+// Insert adds the block to the chain, blocks that would rewrite the finalized chain are refused
+func (sbc *SyncBlockChain) Insert(block p2.Block) bool {
 	sbc.mux.Lock()
+	defer sbc.mux.Unlock()
+	if !sbc.extendsFinalized(block) {
+		return false
+	}
 	sbc.bc.Insert(block)
-	sbc.mux.Unlock()
+	sbc.updateFinalized()
+	return true
+}
+
+// SetFinalityDepth sets how many blocks have to be built on top of a block before it is final, 0 disables finality
+func (sbc *SyncBlockChain) SetFinalityDepth(depth int32) {
+	sbc.mux.Lock()
+	defer sbc.mux.Unlock()
+	sbc.finalityDepth = depth
+	sbc.updateFinalized()
+}
+
+// SetSchedule sets the upgrades deciding the versions of the blocks generated by GenBlock
+func (sbc *SyncBlockChain) SetSchedule(schedule p2.Schedule) {
+	sbc.mux.Lock()
+	defer sbc.mux.Unlock()
+	sbc.schedule = schedule
+}
+
+// Schedule returns the upgrades of the chain
+func (sbc *SyncBlockChain) Schedule() p2.Schedule {
+	sbc.mux.Lock()
+	defer sbc.mux.Unlock()
+	return sbc.schedule
+}
+
+// Finalized returns the height and hash of the finalized checkpoint, the height is 0 if no block is final yet
+func (sbc *SyncBlockChain) Finalized() (int32, string) {
+	sbc.mux.Lock()
+	defer sbc.mux.Unlock()
+	return sbc.finalizedHeight, sbc.finalizedHash
+}
+
+// ExtendsFinalized returns true if the block is a descendant of the finalized checkpoint
+func (sbc *SyncBlockChain) ExtendsFinalized(block p2.Block) bool {
+	sbc.mux.Lock()
+	defer sbc.mux.Unlock()
+	return sbc.extendsFinalized(block)
+}
+
+func (sbc *SyncBlockChain) extendsFinalized(block p2.Block) bool {
+	if sbc.finalizedHeight == 0 {
+		return true
+	}
+	if block.Header.Height <= sbc.finalizedHeight {
+		return block.Header.Height == sbc.finalizedHeight && block.Header.Hash == sbc.finalizedHash
+	}
+	b := block
+	for b.Header.Height > sbc.finalizedHeight+1 {
+		parent, err := sbc.bc.GetParentBlock(b)
+		if err != nil {
+			//Ancestry is unknown, the block cannot be proven to extend the finalized chain
+			return false
+		}
+		b = parent
+	}
+	return b.Header.ParentHash == sbc.finalizedHash
+}
+
+// updateFinalized moves the checkpoint forward along the canonical chain, it never moves backwards
+func (sbc *SyncBlockChain) updateFinalized() {
+	if sbc.finalityDepth <= 0 {
+		return
+	}
+	canonical, err := sbc.bc.Canonical(0)
+	if err != nil {
+		return
+	}
+	target := canonical[0].Header.Height - sbc.finalityDepth
+	if target <= sbc.finalizedHeight {
+		return
+	}
+	for _, b := range canonical {
+		if b.Header.Height == target {
+			if sbc.extendsFinalized(b) {
+				sbc.finalizedHeight = b.Header.Height
+				sbc.finalizedHash = b.Header.Hash
+			}
+			return
+		}
+	}
 }
 
 func (sbc *SyncBlockChain) CheckParentHash(insertBlock p2.Block) bool {
@@ -62,27 +151,50 @@ func (sbc *SyncBlockChain) CheckParentHash(insertBlock p2.Block) bool {
 	return false
 }
 
-func (sbc *SyncBlockChain) UpdateEntireBlockChain(blockChainJson string) {
-	sbc.bc.DecodeFromJSON(blockChainJson)
-}
-
-func (sbc *SyncBlockChain) BlockChainToJson() (string, error) {
-	return sbc.bc.EncodeToJSON()
+// EncodeBlockChain returns the canonical binary encoding of the entire chain
+func (sbc *SyncBlockChain) EncodeBlockChain() []byte {
+	sbc.mux.Lock()
+	defer sbc.mux.Unlock()
+	return sbc.bc.Encode()
 }
 
-func (sbc *SyncBlockChain) GenBlock(mpt p1.MerklePatriciaTrie, nonce string, producer string) p2.Block {
+// GenBlock creates an unsealed block on top of parent with the block version active at its height,
+// a nil parent starts the chain
+func (sbc *SyncBlockChain) GenBlock(parent *p2.Block, mpt p1.MerklePatriciaTrie, producer string) p2.Block {
 	block := new(p2.Block)
-	if sbc.bc.Length == 0 {
-		block.Initial(1, time.Now().UnixNano()/1000000, "Genesis", producer, mpt)
+	if parent == nil {
+		block.Initial(sbc.schedule.At(1).BlockVersion, 1, time.Now().UnixNano()/1000000, "Genesis", producer, mpt)
 	} else {
-		lastBlock := sbc.bc.Chain[sbc.bc.Length][0]
-		block.Initial(lastBlock.Header.Height+1, time.Now().UnixNano()/1000000, lastBlock.Header.Hash, producer, mpt)
+		height := parent.Header.Height + 1
+		block.Initial(sbc.schedule.At(height).BlockVersion, height, time.Now().UnixNano()/1000000, parent.Header.Hash, producer, mpt)
 	}
-	block.Header.Nonce = nonce
-	sbc.Insert(*block)
 	return *block
 }
 
+// Tips returns the blocks above the finalized checkpoint that no block builds on, i.e. the heads of all live forks
+func (sbc *SyncBlockChain) Tips() []p2.Block {
+	sbc.mux.Lock()
+	defer sbc.mux.Unlock()
+	parents := make(map[string]bool)
+	for _, blocks := range sbc.bc.Chain {
+		for _, b := range blocks {
+			parents[b.Header.ParentHash] = true
+		}
+	}
+	tips := make([]p2.Block, 0)
+	for height, blocks := range sbc.bc.Chain {
+		if height <= sbc.finalizedHeight {
+			continue
+		}
+		for _, b := range blocks {
+			if !parents[b.Header.Hash] {
+				tips = append(tips, b)
+			}
+		}
+	}
+	return tips
+}
+
 // GetLatestBlocks returns the lastest block in bc
 func (sbc *SyncBlockChain) GetLatestBlocks() ([]p2.Block, error) {
 	sbc.mux.Lock()
@@ -98,6 +210,8 @@ func (sbc *SyncBlockChain) GetParentBlock(block p2.Block) (p2.Block, error) {
 }
 
 func (sbc *SyncBlockChain) Len() int32 {
+	sbc.mux.Lock()
+	defer sbc.mux.Unlock()
 	return sbc.bc.Len()
 }
 
@@ -137,6 +251,20 @@ func (sbc *SyncBlockChain) Transactions() []tx.Transaction {
 	return sbc.bc.Transactions()
 }
 
+func (sbc *SyncBlockChain) AncestorTransactions(block p2.Block) []tx.Transaction {
+	sbc.mux.Lock()
+	defer sbc.mux.Unlock()
+	return sbc.bc.AncestorTransactions(block)
+}
+
 func (sbc *SyncBlockChain) Show() string {
+	sbc.mux.Lock()
+	defer sbc.mux.Unlock()
 	return sbc.bc.Show()
 }
+
+func (sbc *SyncBlockChain) ShowHash() string {
+	sbc.mux.Lock()
+	defer sbc.mux.Unlock()
+	return sbc.bc.ShowHash()
+}
@@ -1,29 +1,101 @@
 package data
 
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"../../transaction"
+)
+
+// Kinds of inventory items
+const (
+	InvBlock       = "block"
+	InvTransaction = "transaction"
+)
+
+// InvItem announces a block or transaction by its hash, the payload is requested separately
+type InvItem struct {
+	Type   string `json:"type"`
+	Hash   string `json:"hash"`
+	Height int32  `json:"height,omitempty"`
+}
+
+// HeartBeatData is signed together with the Timestamp it was signed at, in milliseconds, so that a captured
+// heartbeat is only accepted within the freshness window
 type HeartBeatData struct {
-	IfNewBlock       bool   `json:"ifNewBlock"`
-	IfNewTransaction bool   `json:"ifNewTransaction"`
-	Id               int32  `json:"id"`
-	BlockJson        string `json:"blockJson"`
-	TransactionJson  string `json:"transactionJson"`
-	PeerMapJson      string `json:"peerMapJson"`
-	Addr             string `json:"addr"`
-	Hops             int32  `json:"hops"`
+	Id          int32     `json:"id"`
+	Inventory   []InvItem `json:"inventory,omitempty"`
+	PeerMapJson string    `json:"peerMapJson"`
+	Addr        string    `json:"addr"`
+	Timestamp   int64     `json:"timestamp"`
+	PublicKey   string    `json:"publicKey"`
+	Signature   string    `json:"signature"`
 }
 
-func NewHeartBeatData(id int32, ifNewBlock bool, blockJson string, ifNewTransaction bool, transactionJson string, peerMapJson string, addr string) HeartBeatData {
+func NewHeartBeatData(id int32, inventory []InvItem, peerMapJson string, addr string) HeartBeatData {
 	hbd := new(HeartBeatData)
-	hbd.IfNewBlock = ifNewBlock
-	hbd.IfNewTransaction = ifNewTransaction
 	hbd.Id = id
-	hbd.BlockJson = blockJson
-	hbd.TransactionJson = transactionJson
+	hbd.Inventory = inventory
 	hbd.PeerMapJson = peerMapJson
 	hbd.Addr = addr
-	hbd.Hops = 2
 	return *hbd
 }
 
+// PrepareHeartBeatData announces the tips of all forks so that peers which missed a block announcement,
+// e.g. during a partition, fetch the blocks they lack
 func PrepareHeartBeatData(sbc *SyncBlockChain, selfId int32, peerMapBase64 string, addr string) HeartBeatData {
-	return NewHeartBeatData(selfId, false, "", false, "", peerMapBase64, addr)
+	var inventory []InvItem
+	for _, block := range sbc.Tips() {
+		inventory = append(inventory, InvItem{Type: InvBlock, Hash: block.Header.Hash, Height: block.Header.Height})
+	}
+	return NewHeartBeatData(selfId, inventory, peerMapBase64, addr)
+}
+
+// Sign timestamps and signs the heartbeat with the sender's long-term key, it has to be called after the last change
+// to the heartbeat
+func (hbd *HeartBeatData) Sign(privateKey *ecdsa.PrivateKey) error {
+	hbd.Timestamp = time.Now().UnixNano() / int64(time.Millisecond)
+	hbd.PublicKey = tx.EncodeECDSAPublicKey(&privateKey.PublicKey)
+	signature, err := ecdsa.SignASN1(rand.Reader, privateKey, hbd.hash())
+	if err != nil {
+		return err
+	}
+	hbd.Signature = hex.EncodeToString(signature)
+	return nil
+}
+
+// Verify returns true if the heartbeat is signed by the key in PublicKey
+func (hbd *HeartBeatData) Verify() bool {
+	if hbd.PublicKey == "" || hbd.Signature == "" {
+		return false
+	}
+	signature, err := hex.DecodeString(hbd.Signature)
+	if err != nil {
+		return false
+	}
+	publicKey, err := tx.DecodeECDSAPublicKey(hbd.PublicKey)
+	if err != nil {
+		return false
+	}
+	return ecdsa.VerifyASN1(publicKey, hbd.hash(), signature)
+}
+
+// Fresh returns true if the heartbeat was signed less than maxAge ago, a clock ahead of ours by less than maxAge is
+// tolerated as well
+func (hbd *HeartBeatData) Fresh(maxAge time.Duration) bool {
+	age := time.Since(time.Unix(0, hbd.Timestamp*int64(time.Millisecond)))
+	return age < maxAge && age > -maxAge
+}
+
+// hash returns the digest of all fields except the signature
+func (hbd *HeartBeatData) hash() []byte {
+	unsigned := *hbd
+	unsigned.Signature = ""
+	bytes, _ := json.Marshal(unsigned)
+	sum := sha256.Sum256(bytes)
+	return sum[:]
 }
@@ -0,0 +1,66 @@
+package data
+
+import (
+	"container/heap"
+	"sync"
+
+	"../../transaction"
+)
+
+// Mempool is a TXQueue that can be shared by the handlers and the miner
+type Mempool struct {
+	queue TXQueue
+	mux   sync.Mutex
+}
+
+func NewMempool() *Mempool {
+	mempool := &Mempool{queue: make(TXQueue, 0)}
+	heap.Init(&mempool.queue)
+	return mempool
+}
+
+// Push queues a transaction prioritised by its fee
+func (mempool *Mempool) Push(t tx.Transaction) {
+	mempool.mux.Lock()
+	defer mempool.mux.Unlock()
+	heap.Push(&mempool.queue, &Item{Value: t, Priority: t.TXFee})
+}
+
+// Pop removes and returns the transaction with the highest fee
+func (mempool *Mempool) Pop() (tx.Transaction, bool) {
+	mempool.mux.Lock()
+	defer mempool.mux.Unlock()
+	if mempool.queue.Len() == 0 {
+		return tx.Transaction{}, false
+	}
+	return heap.Pop(&mempool.queue).(*Item).Value, true
+}
+
+func (mempool *Mempool) Len() int {
+	mempool.mux.Lock()
+	defer mempool.mux.Unlock()
+	return mempool.queue.Len()
+}
+
+func (mempool *Mempool) Contains(t tx.Transaction) bool {
+	mempool.mux.Lock()
+	defer mempool.mux.Unlock()
+	return mempool.queue.Contains(t)
+}
+
+func (mempool *Mempool) Get(hash string) (tx.Transaction, bool) {
+	mempool.mux.Lock()
+	defer mempool.mux.Unlock()
+	return mempool.queue.Get(hash)
+}
+
+// Transactions returns a copy of the queued transactions, highest fee first is not guaranteed
+func (mempool *Mempool) Transactions() []tx.Transaction {
+	mempool.mux.Lock()
+	defer mempool.mux.Unlock()
+	txs := make([]tx.Transaction, 0, len(mempool.queue))
+	for _, item := range mempool.queue {
+		txs = append(txs, item.Value)
+	}
+	return txs
+}
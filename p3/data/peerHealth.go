@@ -0,0 +1,74 @@
+package data
+
+import "time"
+
+// Liveness configures when an unresponsive peer is retried and evicted
+type Liveness struct {
+	MaxFailures int32         // consecutive failures before a peer is evicted
+	Backoff     time.Duration // wait after the first failure, doubled on every further failure
+	MaxBackoff  time.Duration // upper bound of the wait, also how long an evicted peer is kept out
+}
+
+var DefaultLiveness = Liveness{MaxFailures: 5, Backoff: 5 * time.Second, MaxBackoff: 5 * time.Minute}
+
+// PeerHealth tracks whether a peer is responding
+type PeerHealth struct {
+	LastSeen    time.Time `json:"lastSeen"`
+	Failures    int32     `json:"failures"`
+	NextAttempt time.Time `json:"nextAttempt"`
+}
+
+// PeerStatus is a peer as displayed by Show
+type PeerStatus struct {
+	Id int32 `json:"id"`
+	PeerHealth
+	Healthy bool `json:"healthy"`
+}
+
+func (peers *PeerList) SetLiveness(liveness Liveness) {
+	peers.mux.Lock()
+	peers.liveness = liveness
+	peers.mux.Unlock()
+}
+
+// Seen records that the peer responded or contacted us
+func (peers *PeerList) Seen(addr string) {
+	peers.mux.Lock()
+	defer peers.mux.Unlock()
+	if health, ok := peers.health[addr]; ok {
+		health.LastSeen = time.Now()
+		health.Failures = 0
+		health.NextAttempt = time.Time{}
+	}
+}
+
+// Failed records a failed attempt to reach the peer and backs off, returns true if the peer got evicted
+func (peers *PeerList) Failed(addr string) bool {
+	peers.mux.Lock()
+	defer peers.mux.Unlock()
+	health, ok := peers.health[addr]
+	if !ok {
+		return false
+	}
+	health.Failures++
+	if health.Failures >= peers.liveness.MaxFailures {
+		delete(peers.peerMap, addr)
+		delete(peers.health, addr)
+		peers.evicted[addr] = time.Now().Add(peers.liveness.MaxBackoff)
+		return true
+	}
+	backoff := peers.liveness.Backoff << uint(health.Failures-1)
+	if backoff > peers.liveness.MaxBackoff || backoff <= 0 {
+		backoff = peers.liveness.MaxBackoff
+	}
+	health.NextAttempt = time.Now().Add(backoff)
+	return false
+}
+
+// Due returns false while the peer is backing off after a failure
+func (peers *PeerList) Due(addr string) bool {
+	peers.mux.Lock()
+	defer peers.mux.Unlock()
+	health, ok := peers.health[addr]
+	return !ok || !time.Now().Before(health.NextAttempt)
+}
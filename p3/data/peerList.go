@@ -1,17 +1,27 @@
 package data
 
 import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"reflect"
 	"sort"
 	"sync"
+	"time"
+
+	"../../transaction"
 )
 
 type PeerList struct {
 	selfId    int32
 	peerMap   map[string]int32
+	keys      map[int32]string
+	health    map[string]*PeerHealth
+	evicted   map[string]time.Time
 	maxLength int32
+	liveness  Liveness
 	mux       sync.Mutex
 }
 
@@ -20,18 +30,77 @@ func NewPeerList(id int32, maxLength int32) PeerList {
 	peerList.selfId = id
 	peerList.maxLength = maxLength
 	peerList.peerMap = make(map[string]int32)
+	peerList.keys = make(map[int32]string)
+	peerList.health = make(map[string]*PeerHealth)
+	peerList.evicted = make(map[string]time.Time)
+	peerList.liveness = DefaultLiveness
 	return *peerList
 }
 
+// Add adds an authenticated peer, a previous address of the same id is replaced
 func (peers *PeerList) Add(addr string, id int32) {
 	peers.mux.Lock()
-	peers.peerMap[addr] = id
+	for k, v := range peers.peerMap {
+		if v == id && k != addr {
+			delete(peers.peerMap, k)
+			delete(peers.health, k)
+		}
+	}
+	peers.add(addr, id)
+	delete(peers.evicted, addr)
 	peers.mux.Unlock()
 }
 
+func (peers *PeerList) add(addr string, id int32) {
+	peers.peerMap[addr] = id
+	if _, ok := peers.health[addr]; !ok {
+		peers.health[addr] = new(PeerHealth)
+	}
+}
+
+// maxKeys bounds the ids Bind keeps a key for, the keys of ids that are not in the peer list make room
+const maxKeys = 4096
+
+// Bind binds a node id to the public key it signs with, returns false if the id is already bound to another key
+func (peers *PeerList) Bind(id int32, publicKey string) bool {
+	peers.mux.Lock()
+	defer peers.mux.Unlock()
+	if key, ok := peers.keys[id]; ok {
+		return key == publicKey
+	}
+	if len(peers.keys) >= maxKeys {
+		peers.forgetKey()
+	}
+	peers.keys[id] = publicKey
+	return true
+}
+
+// forgetKey drops the key of an id that is neither this node nor in the peer list, must hold the lock
+func (peers *PeerList) forgetKey() {
+	listed := map[int32]bool{peers.selfId: true}
+	for _, id := range peers.peerMap {
+		listed[id] = true
+	}
+	for id := range peers.keys {
+		if !listed[id] {
+			delete(peers.keys, id)
+			return
+		}
+	}
+}
+
+// Key returns the public key bound to the node id
+func (peers *PeerList) Key(id int32) (string, bool) {
+	peers.mux.Lock()
+	defer peers.mux.Unlock()
+	key, ok := peers.keys[id]
+	return key, ok
+}
+
 func (peers *PeerList) Delete(addr string) {
 	peers.mux.Lock()
 	delete(peers.peerMap, addr)
+	delete(peers.health, addr)
 	peers.mux.Unlock()
 }
 
@@ -49,6 +118,13 @@ func min(x, y int) int {
 	return x
 }
 
+// ringEntry is a position on the id ring, peers are ordered by id and then by address so colliding ids keep a stable order
+type ringEntry struct {
+	id   int32
+	addr string
+	self bool
+}
+
 func (peers *PeerList) Rebalance() {
 	selfID := peers.GetSelfId()
 	peers.mux.Lock()
@@ -57,45 +133,54 @@ func (peers *PeerList) Rebalance() {
 		return
 	}
 
-	invertedPeerMap := make(map[int32]string)
-	ids := []int32{}
+	ring := []ringEntry{}
 	for k, v := range peers.peerMap {
-		invertedPeerMap[v] = k
-		ids = append(ids, v)
+		ring = append(ring, ringEntry{id: v, addr: k})
 	}
-	peers.peerMap = make(map[string]int32)
-	ids = append(ids, selfID)
-	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	ring = append(ring, ringEntry{id: selfID, self: true})
+	sort.Slice(ring, func(i, j int) bool {
+		if ring[i].id != ring[j].id {
+			return ring[i].id < ring[j].id
+		}
+		return ring[i].addr < ring[j].addr
+	})
 
 	//find the center of the ring
 	selfIndex := 0
-	for i, v := range ids {
-		if v == selfID {
+	for i, v := range ring {
+		if v.self {
 			selfIndex = i
 			break
 		}
 	}
 
-	newIds := []int32{}
-	idsl := len(ids)
+	peers.peerMap = make(map[string]int32)
+	ringl := len(ring)
 	var left, right int
 	//record upto maxLength / 2 elements from both sides
-	for diff := 1; diff <= min(idsl, int(peers.maxLength/2)); diff++ {
-		left = (selfIndex - diff + idsl) % idsl
-		right = (selfIndex + diff) % idsl
-		newIds = append(newIds, ids[left])
-		newIds = append(newIds, ids[right])
+	for diff := 1; diff <= min(ringl-1, int(peers.maxLength/2)); diff++ {
+		left = (selfIndex - diff + ringl) % ringl
+		right = (selfIndex + diff) % ringl
+		peers.peerMap[ring[left].addr] = ring[left].id
+		peers.peerMap[ring[right].addr] = ring[right].id
 	}
-	for _, v := range newIds {
-		peers.peerMap[invertedPeerMap[v]] = v
+	for addr := range peers.health {
+		if _, ok := peers.peerMap[addr]; !ok {
+			delete(peers.health, addr)
+		}
 	}
-
 }
 
+// Show displays the peers with their id and health
 func (peers *PeerList) Show() string {
 	peers.mux.Lock()
-	output, _ := json.Marshal(peers.peerMap)
-	peers.mux.Unlock()
+	defer peers.mux.Unlock()
+	shown := make(map[string]PeerStatus)
+	for addr, id := range peers.peerMap {
+		health := *peers.health[addr]
+		shown[addr] = PeerStatus{Id: id, PeerHealth: health, Healthy: health.Failures == 0 && !health.LastSeen.IsZero()}
+	}
+	output, _ := json.Marshal(shown)
 	return string(output)
 }
 
@@ -120,7 +205,10 @@ func (peers *PeerList) GetSelfId() int32 {
 }
 
 func (peers *PeerList) PeerMapToJson() (string, error) {
-	return peers.Show(), nil
+	peers.mux.Lock()
+	output, err := json.Marshal(peers.peerMap)
+	peers.mux.Unlock()
+	return string(output), err
 }
 
 func (peers *PeerList) InjectPeerMapJson(peerMapJsonStr string, selfAddr string) {
@@ -128,13 +216,35 @@ func (peers *PeerList) InjectPeerMapJson(peerMapJsonStr string, selfAddr string)
 	json.Unmarshal([]byte(peerMapJsonStr), &tempMap)
 	peers.mux.Lock()
 	for k, v := range tempMap {
-		if k != selfAddr {
-			peers.peerMap[k] = v
+		if k == selfAddr || v == peers.selfId || peers.hasIdElsewhere(k, v) {
+			continue
 		}
+		//Peers evicted recently are not taken back from other peers' maps
+		if until, ok := peers.evicted[k]; ok && time.Now().Before(until) {
+			continue
+		}
+		peers.add(k, v)
 	}
 	peers.mux.Unlock()
 }
 
+// hasIdElsewhere returns true if the id is already known under another address, must hold the lock
+func (peers *PeerList) hasIdElsewhere(addr string, id int32) bool {
+	for k, v := range peers.peerMap {
+		if v == id && k != addr {
+			return true
+		}
+	}
+	return false
+}
+
+// NodeIdFromKey derives the node id from the canonical encoding of the node's public key, so that every key has
+// exactly one id however its hex was written
+func NodeIdFromKey(publicKey *ecdsa.PublicKey) int32 {
+	sum := sha256.Sum256([]byte(tx.EncodeECDSAPublicKey(publicKey)))
+	return int32(binary.BigEndian.Uint32(sum[:4]))
+}
+
 func TestPeerListRebalance() {
 	peers := NewPeerList(5, 4)
 	peers.Add("1111", 1)
@@ -0,0 +1,201 @@
+package data
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"../../api"
+)
+
+// Score changes applied to a peer for each kind of behaviour
+const (
+	ScoreInvalidBlock       int32 = -50
+	ScoreInvalidTransaction int32 = -20
+	ScoreTimeout            int32 = -5
+	ScoreValidBlock         int32 = 10
+	ScoreValidTransaction   int32 = 1
+
+	maxScore int32 = 100
+)
+
+// maxTracked bounds the peers a Reputation keeps a score or an address for, so that peers coming and going with new
+// keys or addresses cannot grow it without limit
+const maxTracked = 4096
+
+// Reputation scores peers by the key their heartbeats are signed with and bans the ones whose score drops to the
+// threshold. Records for an address are charged to the key that last sent an authenticated heartbeat from it, a
+// peer that never authenticated is scored by its address. A ban covers the key and the address, so a banned peer
+// comes back neither by advertising another address nor by signing with another key from the same address.
+// Scores that are back to neutral are forgotten
+type Reputation struct {
+	scores      map[string]int32
+	keys        map[string]string
+	bans        map[string]time.Time
+	threshold   int32
+	banDuration time.Duration
+	banFile     string
+	mux         sync.Mutex
+}
+
+// NewReputation creates a Reputation, the ban list is loaded from and saved to banFile unless it is empty
+func NewReputation(threshold int32, banDuration time.Duration, banFile string) (*Reputation, error) {
+	rep := &Reputation{
+		scores:      make(map[string]int32),
+		keys:        make(map[string]string),
+		bans:        make(map[string]time.Time),
+		threshold:   threshold,
+		banDuration: banDuration,
+		banFile:     banFile,
+	}
+	if banFile == "" {
+		return rep, nil
+	}
+	bytes, err := ioutil.ReadFile(banFile)
+	if os.IsNotExist(err) {
+		return rep, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bytes, &rep.bans); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+// Identify records that an authenticated heartbeat signed with key came from addr
+func (rep *Reputation) Identify(addr string, key string) {
+	rep.mux.Lock()
+	defer rep.mux.Unlock()
+	if _, ok := rep.keys[addr]; !ok && len(rep.keys) >= maxTracked {
+		for k := range rep.keys {
+			delete(rep.keys, k)
+			break
+		}
+	}
+	rep.keys[addr] = key
+}
+
+// peer returns the key records for addr are charged to, the address itself if no key was identified from it
+func (rep *Reputation) peer(addr string) string {
+	if key, ok := rep.keys[addr]; ok {
+		return key
+	}
+	return addr
+}
+
+// Record applies delta to the score of the peer at addr, returns true if the peer got banned
+func (rep *Reputation) Record(addr string, delta int32) bool {
+	rep.mux.Lock()
+	defer rep.mux.Unlock()
+	peer := rep.peer(addr)
+	score := rep.scores[peer] + delta
+	if score > maxScore {
+		score = maxScore
+	}
+	if score > rep.threshold {
+		rep.setScore(peer, score)
+		return false
+	}
+	delete(rep.scores, peer)
+	until := time.Now().Add(rep.banDuration)
+	rep.bans[peer] = until
+	rep.bans[addr] = until
+	rep.save()
+	return true
+}
+
+// setScore keeps the score of a peer, a neutral score is forgotten. If too many peers are tracked the score
+// closest to neutral makes room, must hold the lock
+func (rep *Reputation) setScore(peer string, score int32) {
+	if score == 0 {
+		delete(rep.scores, peer)
+		return
+	}
+	if _, ok := rep.scores[peer]; !ok && len(rep.scores) >= maxTracked {
+		closest := ""
+		for p, s := range rep.scores {
+			if closest == "" || abs(s) < abs(rep.scores[closest]) {
+				closest = p
+			}
+		}
+		delete(rep.scores, closest)
+	}
+	rep.scores[peer] = score
+}
+
+func abs(x int32) int32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// IsBanned returns true if the address or key, or the key identified from the address, is currently banned.
+// Expired bans are lifted
+func (rep *Reputation) IsBanned(addr string) bool {
+	rep.mux.Lock()
+	defer rep.mux.Unlock()
+	return rep.banned(addr) || rep.banned(rep.peer(addr))
+}
+
+// banned checks a single ban, must hold the lock
+func (rep *Reputation) banned(peer string) bool {
+	until, ok := rep.bans[peer]
+	if !ok {
+		return false
+	}
+	if time.Now().Before(until) {
+		return true
+	}
+	delete(rep.bans, peer)
+	rep.save()
+	return false
+}
+
+// Show returns the reputation of the given peers and of all banned peers, sorted by address
+func (rep *Reputation) Show(addrs []string) []api.PeerReputation {
+	rep.mux.Lock()
+	defer rep.mux.Unlock()
+	seen := make(map[string]bool)
+	output := make([]api.PeerReputation, 0)
+	for _, addr := range addrs {
+		seen[addr] = true
+		output = append(output, rep.get(addr))
+	}
+	for addr := range rep.bans {
+		if !seen[addr] {
+			output = append(output, rep.get(addr))
+		}
+	}
+	sort.Slice(output, func(i, j int) bool { return output[i].Addr < output[j].Addr })
+	return output
+}
+
+func (rep *Reputation) get(addr string) api.PeerReputation {
+	peer := rep.peer(addr)
+	pr := api.PeerReputation{Addr: addr, Score: rep.scores[peer]}
+	for _, banned := range []string{addr, peer} {
+		if until, ok := rep.bans[banned]; ok && time.Now().Before(until) {
+			pr.Banned = true
+			pr.BannedUntil = until
+		}
+	}
+	return pr
+}
+
+// save writes the ban list to banFile, must hold the lock
+func (rep *Reputation) save() error {
+	if rep.banFile == "" {
+		return nil
+	}
+	bytes, err := json.Marshal(rep.bans)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(rep.banFile, bytes, 0644)
+}
@@ -0,0 +1,58 @@
+package data
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestReputationBansKeyAndAddress(t *testing.T) {
+	rep, err := NewReputation(-100, time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep.Identify("http://a:1", "key-a")
+	if rep.Record("http://a:1", -60) {
+		t.Fatal("banned above the threshold")
+	}
+	//A record for another address of the same key adds to the same score
+	rep.Identify("http://a:1/", "key-a")
+	if !rep.Record("http://a:1/", -60) {
+		t.Fatal("not banned at the threshold")
+	}
+	tests := []struct {
+		peer   string
+		banned bool
+	}{
+		{"key-a", true},
+		{"http://a:1/", true},
+		{"http://a:1", true},
+		{"key-b", false},
+		{"http://b:1", false},
+	}
+	for _, test := range tests {
+		if banned := rep.IsBanned(test.peer); banned != test.banned {
+			t.Errorf("IsBanned(%q) = %v, want %v", test.peer, banned, test.banned)
+		}
+	}
+}
+
+func TestReputationForgetsNeutralScores(t *testing.T) {
+	rep, _ := NewReputation(-100, time.Hour, "")
+	rep.Record("http://a:1", ScoreTimeout)
+	rep.Record("http://a:1", -ScoreTimeout)
+	if len(rep.scores) != 0 {
+		t.Fatalf("neutral score kept: %v", rep.scores)
+	}
+	for i := 0; i < maxTracked+10; i++ {
+		rep.Record(fmt.Sprintf("http://peer:%d", i), ScoreValidTransaction)
+		rep.Identify(fmt.Sprintf("http://peer:%d", i), fmt.Sprintf("key-%d", i))
+	}
+	rep.Record("http://bad:1", ScoreInvalidBlock)
+	if len(rep.scores) > maxTracked || len(rep.keys) > maxTracked {
+		t.Fatalf("tracking %d scores and %d keys, want at most %d", len(rep.scores), len(rep.keys), maxTracked)
+	}
+	if rep.scores["http://bad:1"] != ScoreInvalidBlock {
+		t.Fatal("the score furthest from neutral was evicted")
+	}
+}
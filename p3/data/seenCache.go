@@ -0,0 +1,75 @@
+package data
+
+import (
+	"sync"
+	"time"
+)
+
+// SeenCache remembers the hashes of recently announced blocks and transactions so each is downloaded only once,
+// it also keeps the payloads this node can serve to peers that ask for them
+type SeenCache struct {
+	entries   map[string]*seenEntry
+	ttl       time.Duration
+	lastPrune time.Time
+	mux       sync.Mutex
+}
+
+type seenEntry struct {
+	expires time.Time
+	payload []byte
+}
+
+func NewSeenCache(ttl time.Duration) *SeenCache {
+	return &SeenCache{entries: make(map[string]*seenEntry), ttl: ttl, lastPrune: time.Now()}
+}
+
+// MarkSeen marks the hash as seen, returns false if it has been seen before
+func (cache *SeenCache) MarkSeen(hash string) bool {
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+	cache.prune()
+	if _, ok := cache.entries[hash]; ok {
+		return false
+	}
+	cache.entries[hash] = &seenEntry{expires: time.Now().Add(cache.ttl)}
+	return true
+}
+
+// Forget removes the hash so that the item can be requested again from another peer
+func (cache *SeenCache) Forget(hash string) {
+	cache.mux.Lock()
+	delete(cache.entries, hash)
+	cache.mux.Unlock()
+}
+
+// Keep marks the hash as seen and stores the payload for serving it to peers
+func (cache *SeenCache) Keep(hash string, payload []byte) {
+	cache.mux.Lock()
+	cache.entries[hash] = &seenEntry{expires: time.Now().Add(cache.ttl), payload: payload}
+	cache.mux.Unlock()
+}
+
+// Payload returns the payload kept for the hash
+func (cache *SeenCache) Payload(hash string) ([]byte, bool) {
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+	entry, ok := cache.entries[hash]
+	if !ok || entry.payload == nil {
+		return nil, false
+	}
+	return entry.payload, true
+}
+
+// prune drops expired entries at most once per ttl, must hold the lock
+func (cache *SeenCache) prune() {
+	now := time.Now()
+	if now.Sub(cache.lastPrune) < cache.ttl {
+		return
+	}
+	for hash, entry := range cache.entries {
+		if now.After(entry.expires) {
+			delete(cache.entries, hash)
+		}
+	}
+	cache.lastPrune = now
+}
@@ -70,3 +70,13 @@ func (txl *TXQueue) Contains(t tx.Transaction) bool {
 	}
 	return false
 }
+
+// Get returns the queued transaction with the given hash
+func (txl *TXQueue) Get(hash string) (tx.Transaction, bool) {
+	for _, k := range *txl {
+		if k.Value.Hash == hash {
+			return k.Value, true
+		}
+	}
+	return tx.Transaction{}, false
+}
@@ -0,0 +1,250 @@
+package p3
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"../api"
+	"../p2"
+	"../transaction"
+)
+
+// EVENT_BUFFER is the number of events queued for a subscriber, a subscriber that falls further behind is
+// disconnected and has to subscribe again
+const EVENT_BUFFER = 256
+
+// EVENT_KEEPALIVE is how often an idle stream gets a comment so that dead connections are noticed
+const EVENT_KEEPALIVE = 15 * time.Second
+
+// eventFilter selects the events of a subscriber, the empty filter selects all events. A transaction concerns an
+// account or a merit if it is sent by the account or publishes the merit, or if it refers to a merit or an
+// acceptance that concerns it. So an applicant learns about the acceptances of the merits and an employer about
+// the confirmations of the acceptances
+type eventFilter struct {
+	address string
+	merit   string
+	txType  string
+	related map[string]bool // hashes of the merits and acceptances that concern the filter
+}
+
+func (filter *eventFilter) match(t tx.Transaction, fromAddress string) bool {
+	concerned := filter.related[t.To] ||
+		(filter.address == "" || fromAddress == filter.address) && (filter.merit == "" || meritHash(t) == filter.merit)
+	if concerned && (filter.address != "" || filter.merit != "") {
+		switch t.TXType {
+		case tx.TYPE_APPLICATION:
+			if merit := meritHash(t); merit != "" {
+				filter.related[merit] = true
+			}
+		case tx.TYPE_ACCEPTANCE:
+			filter.related[t.Hash] = true
+		}
+	}
+	return concerned && (filter.txType == "" || t.TXType == filter.txType)
+}
+
+// meritHash returns the hash of the merit published by an application, "" for other transactions
+func meritHash(t tx.Transaction) string {
+	if t.TXType != tx.TYPE_APPLICATION {
+		return ""
+	}
+	payload, err := t.DecodePayload()
+	if err != nil {
+		return ""
+	}
+	return payload.(*tx.ApplicationPayload).Hash
+}
+
+type subscriber struct {
+	filter *eventFilter
+	events chan api.Event
+}
+
+// eventHub fans the events out to the subscribers. The transactions of the canonical chain are only tracked while
+// there are subscribers, they are compared with the chain after every inserted block
+type eventHub struct {
+	subscribers map[*subscriber]bool
+	chain       []api.ChainTransaction
+	mux         sync.Mutex
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[*subscriber]bool)}
+}
+
+// subscribe adds a subscriber, the filter first learns the merits and acceptances of the chain and the mempool
+func (node *Node) subscribe(filter *eventFilter) *subscriber {
+	hub := node.events
+	hub.mux.Lock()
+	defer hub.mux.Unlock()
+	chain := node.canonicalTransactions()
+	if len(hub.subscribers) == 0 {
+		hub.chain = chain
+	}
+	known := make([]tx.Transaction, 0, len(chain))
+	for _, t := range chain {
+		known = append(known, t.Transaction)
+	}
+	known = append(known, node.mempool.Transactions()...)
+	//Acceptances refer to merits, so the merits have to be learned first
+	for _, txType := range []string{tx.TYPE_APPLICATION, tx.TYPE_ACCEPTANCE} {
+		for _, t := range known {
+			if t.TXType == txType {
+				filter.match(t, t.FromAddress())
+			}
+		}
+	}
+	s := &subscriber{filter: filter, events: make(chan api.Event, EVENT_BUFFER)}
+	hub.subscribers[s] = true
+	return s
+}
+
+func (node *Node) unsubscribe(s *subscriber) {
+	hub := node.events
+	hub.mux.Lock()
+	defer hub.mux.Unlock()
+	delete(hub.subscribers, s)
+	if len(hub.subscribers) == 0 {
+		hub.chain = nil
+	}
+}
+
+// publish queues the event for the subscribers it matches, hub.mux has to be held
+func (hub *eventHub) publish(e api.Event) {
+	for s := range hub.subscribers {
+		if !s.filter.match(e.Transaction, e.FromAddress) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			fmt.Printf("Event subscriber fell behind, disconnected\n")
+			delete(hub.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+// transactionQueued publishes that a transaction entered the mempool
+func (node *Node) transactionQueued(t tx.Transaction) {
+	hub := node.events
+	hub.mux.Lock()
+	defer hub.mux.Unlock()
+	hub.publish(api.Event{Event: api.EVENT_PENDING, ChainTransaction: api.ChainTransaction{Transaction: t, FromAddress: t.FromAddress()}})
+}
+
+// chainChanged compares the canonical chain with the last one seen and publishes the transactions that left it and
+// those that were mined. While forks at the tip are tied the canonical chain ends below them, the transactions of
+// the tied blocks are reorged and mined again once a fork wins
+func (node *Node) chainChanged() {
+	hub := node.events
+	hub.mux.Lock()
+	defer hub.mux.Unlock()
+	if len(hub.subscribers) == 0 {
+		return
+	}
+	chain := node.canonicalTransactions()
+	blocks := make(map[string]string)
+	for _, t := range chain {
+		blocks[t.Hash] = t.BlockHash
+	}
+	previous := make(map[string]string)
+	for _, t := range hub.chain {
+		previous[t.Hash] = t.BlockHash
+		if blocks[t.Hash] != t.BlockHash {
+			hub.publish(api.Event{Event: api.EVENT_REORGED, ChainTransaction: t})
+		}
+	}
+	//The canonical transactions are listed from the tip, they are published in the order they were mined
+	for i := len(chain) - 1; i >= 0; i-- {
+		if previous[chain[i].Hash] != chain[i].BlockHash {
+			hub.publish(api.Event{Event: api.EVENT_MINED, ChainTransaction: chain[i]})
+		}
+	}
+	hub.chain = chain
+}
+
+// insertBlock inserts a verified block into the chain and publishes the transactions it mined or reorged
+func (node *Node) insertBlock(block p2.Block) bool {
+	if !node.sbc.Insert(block) {
+		return false
+	}
+	node.chainChanged()
+	return true
+}
+
+// eventQuery returns the filter of a subscription from the query ?address=, ?key=, ?merit= and ?type=. A malformed
+// query is answered with 400 and ok is false
+func eventQuery(w http.ResponseWriter, r *http.Request) (filter *eventFilter, ok bool) {
+	address, ok := addressQuery(w, r)
+	if !ok {
+		return nil, false
+	}
+	query := r.URL.Query()
+	if key := query.Get("key"); key != "" {
+		keyAddress, err := tx.AddressFromPublicKey(key)
+		if err != nil {
+			http.Error(w, "invalid key: "+err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+		if address != "" && address != keyAddress {
+			http.Error(w, "key and address are different accounts", http.StatusBadRequest)
+			return nil, false
+		}
+		address = keyAddress
+	}
+	txType := query.Get("type")
+	if txType != "" {
+		if _, err := tx.NewPayload(txType); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	return &eventFilter{address: address, merit: query.Get("merit"), txType: txType, related: make(map[string]bool)}, true
+}
+
+// Events streams the events of the transactions matching the query as server-sent events until the client goes
+// away. Every event is a json api.Event named after its kind:
+//
+//	event: mined
+//	data: {"event":"mined","hash":"...","blockHeight":12,...}
+func (node *Node) Events(w http.ResponseWriter, r *http.Request) {
+	filter, ok := eventQuery(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	s := node.subscribe(filter)
+	defer node.unsubscribe(s)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	keepalive := time.NewTicker(EVENT_KEEPALIVE)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-node.ctx.Done():
+			return
+		case e, ok := <-s.events:
+			if !ok {
+				return
+			}
+			eventJSON, _ := json.Marshal(e)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Event, eventJSON)
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,46 @@
+package p3
+
+import (
+	"../api"
+	"../transaction"
+)
+
+// canonicalTransactions returns all transactions of the canonical chain with their confirmations
+func (node *Node) canonicalTransactions() []api.ChainTransaction {
+	canonical, err := node.sbc.Canonical(0)
+	transactions := make([]api.ChainTransaction, 0)
+	if err != nil {
+		return transactions
+	}
+	tipHeight := canonical[0].Header.Height
+	finalizedHeight, _ := node.sbc.Finalized()
+	for _, b := range canonical {
+		finality := api.Finality{
+			BlockHeight:   b.Header.Height,
+			BlockHash:     b.Header.Hash,
+			Confirmations: tipHeight - b.Header.Height + 1,
+			Finalized:     b.Header.Height <= finalizedHeight,
+		}
+		txs, _ := b.Transactions()
+		for _, t := range txs {
+			transactions = append(transactions, api.ChainTransaction{Transaction: t, FromAddress: t.FromAddress(), Finality: finality})
+		}
+	}
+	return transactions
+}
+
+// canonicalMerits returns all merits of the canonical chain with their confirmations
+func (node *Node) canonicalMerits() []api.ChainMerit {
+	merits := make([]api.ChainMerit, 0)
+	for _, t := range node.canonicalTransactions() {
+		if t.TXType != tx.TYPE_APPLICATION {
+			continue
+		}
+		payload, err := t.DecodePayload()
+		if err != nil {
+			continue
+		}
+		merits = append(merits, api.ChainMerit{SignedMerit: payload.(*tx.ApplicationPayload).SignedMerit, Address: t.FromAddress, Finality: t.Finality})
+	}
+	return merits
+}
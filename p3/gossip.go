@@ -0,0 +1,163 @@
+package p3
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"../p2"
+	"../transaction"
+	"./data"
+)
+
+// Announce sends the inventory to every peer except the one it came from
+func (node *Node) Announce(inventory []data.InvItem, except string) {
+	node.peers.Rebalance()
+	peerMapJSON, _ := node.peers.PeerMapToJson()
+	hbd := data.NewHeartBeatData(node.id, inventory, peerMapJSON, node.getSelfAddr())
+	hbd.Sign(node.minerKey)
+	hbdJSON, _ := json.Marshal(hbd)
+
+	pm := node.peers.Copy()
+	for k := range pm {
+		if k != except {
+			node.sendHeartBeat(k, hbdJSON)
+		}
+	}
+}
+
+// announceBlock keeps a block this node accepted and announces it to the peers
+func (node *Node) announceBlock(block p2.Block, except string) {
+	node.seen.Keep(block.Header.Hash, block.Encode())
+	node.Announce([]data.InvItem{{Type: data.InvBlock, Hash: block.Header.Hash, Height: block.Header.Height}}, except)
+}
+
+// announceTransaction keeps a transaction this node accepted and announces it to the peers
+func (node *Node) announceTransaction(t tx.Transaction, except string) {
+	node.seen.Keep(t.Hash, t.Encode())
+	node.Announce([]data.InvItem{{Type: data.InvTransaction, Hash: t.Hash}}, except)
+}
+
+// handleInventory downloads the announced items that have not been seen yet from the announcing peer
+func (node *Node) handleInventory(from string, inventory []data.InvItem) {
+	for _, item := range inventory {
+		if node.ctx.Err() != nil {
+			return
+		}
+		//An item that is already stored is not marked seen, a block or transaction forgotten by the seen set
+		//after SeenTTL is not downloaded again
+		if node.hasItem(item) || !node.seen.MarkSeen(item.Hash) {
+			continue
+		}
+		switch item.Type {
+		case data.InvBlock:
+			block, err := node.fetchBlock(from, item.Height, item.Hash)
+			if err != nil {
+				node.seen.Forget(item.Hash)
+				continue
+			}
+			if !node.hasParent(block) && !node.AskForBlock(block.Header.Height-1, block.Header.ParentHash) {
+				//The ancestors could not be fetched, the block is retried when it is announced again
+				node.seen.Forget(item.Hash)
+				continue
+			}
+			switch node.verifyBlock(block) {
+			case nil:
+				node.insertBlock(block)
+				node.recordPeer(from, data.ScoreValidBlock)
+				node.announceBlock(block, from)
+			case errInvalid:
+				node.recordPeer(from, data.ScoreInvalidBlock)
+			}
+		case data.InvTransaction:
+			t, err := node.fetchTransaction(from, item.Hash)
+			if err != nil {
+				node.seen.Forget(item.Hash)
+				continue
+			}
+			switch node.processNewTransaction(t) {
+			case nil:
+				node.recordPeer(from, data.ScoreValidTransaction)
+				node.announceTransaction(t, from)
+			case errInvalid:
+				node.recordPeer(from, data.ScoreInvalidTransaction)
+			}
+		}
+	}
+}
+
+// hasItem returns true if the block is in the chain or the transaction is in the mempool or in the chain
+func (node *Node) hasItem(item data.InvItem) bool {
+	switch item.Type {
+	case data.InvBlock:
+		_, ok := node.sbc.GetBlock(item.Height, item.Hash)
+		return ok
+	case data.InvTransaction:
+		if _, ok := node.mempool.Get(item.Hash); ok {
+			return true
+		}
+		return node.sbc.ContainsTransaction(tx.Transaction{Hash: item.Hash})
+	}
+	return false
+}
+
+// fetchBlock requests a block from a peer, a peer that answers with a malformed or another block does not have it
+func (node *Node) fetchBlock(addr string, height int32, hash string) (p2.Block, error) {
+	encoded, err := node.transport.FetchBlock(addr, height, hash)
+	if err != nil {
+		return p2.Block{}, err
+	}
+	block := new(p2.Block)
+	if err := block.Decode(encoded); err != nil || block.Header.Hash != hash {
+		return p2.Block{}, errNotFound
+	}
+	return *block, nil
+}
+
+// fetchTransaction requests a transaction from a peer
+func (node *Node) fetchTransaction(addr string, hash string) (tx.Transaction, error) {
+	encoded, err := node.transport.FetchTransaction(addr, hash)
+	if err != nil {
+		return tx.Transaction{}, err
+	}
+	t := new(tx.Transaction)
+	if err := t.Decode(encoded); err != nil || t.Hash != hash {
+		return tx.Transaction{}, errNotFound
+	}
+	return *t, nil
+}
+
+// Upload a transaction from the mempool or the chain to whoever called this method,
+// peers get the binary encoding and api clients json
+func (node *Node) UploadTransaction(w http.ResponseWriter, r *http.Request) {
+	hash := strings.Split(r.URL.Path, "/")[2]
+	t, ok := node.findTransaction(hash)
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if acceptsBinary(r) {
+		writeBinary(w, t.Encode())
+		return
+	}
+	tjson, _ := t.EncodeToJSON()
+	fmt.Fprint(w, tjson)
+}
+
+// findTransaction looks a transaction up in the gossip cache, the mempool and the chain
+func (node *Node) findTransaction(hash string) (tx.Transaction, bool) {
+	t := new(tx.Transaction)
+	if encoded, ok := node.seen.Payload(hash); ok && t.Decode(encoded) == nil {
+		return *t, true
+	}
+	if t, ok := node.mempool.Get(hash); ok {
+		return t, true
+	}
+	for _, t := range node.sbc.Transactions() {
+		if t.Hash == hash {
+			return t, true
+		}
+	}
+	return tx.Transaction{}, false
+}
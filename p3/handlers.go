@@ -1,129 +1,107 @@
 package p3
 
 import (
-	"bytes"
-	"container/heap"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	crand "crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"math/rand"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
-	"../models"
+	"../api"
 	"../p1"
 	"../p2"
 	"../transaction"
 	"./data"
-	"golang.org/x/crypto/sha3"
 )
 
-var DIFFICULTY = "000000"
-
-var FIRST_NODE_HOST string
-var PORT string
-var NODEID string
-
-var bcDownloadServer string
-var selfAddr string
-
-var SBC data.SyncBlockChain
-var Peers data.PeerList
-var TransactionQueue data.TXQueue
-var ifStarted bool
-var nodeID int32
-
-var minerPrivateKey *ecdsa.PrivateKey
-
-func init() {
-	// This function will be executed before everything else.
-	// Do some initialization here.
-	SBC = data.NewBlockChain()
-	ifStarted = false
-	minerPrivateKey, _ = ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
-
-	go func() {
-		time.Sleep(time.Duration(3) * time.Second)
-		go start()
-	}()
-}
-
-func start() {
-	bcDownloadServer = FIRST_NODE_HOST + "/upload"
-	selfAddr = "http://localhost:" + PORT
-	temp, _ := strconv.ParseInt(NODEID, 0, 32)
-	nodeID = int32(temp)
-	Peers = data.NewPeerList(nodeID, 32)
-	TransactionQueue = make(data.TXQueue, 0)
-	heap.Init(&TransactionQueue)
-	if FIRST_NODE_HOST == "http://" {
-		go StartHeartBeat()
-	} else {
-		Download()
-		go StartHeartBeat()
-	}
-	go StartTryingNonces()
-	ifStarted = true
-}
-
-// Register ID, download BlockChain, start HeartBeat
-func Start(w http.ResponseWriter, r *http.Request) {
-	if ifStarted {
-		fmt.Fprintln(w, "already started")
-		return
-	}
-	start()
+// Reasons for refusing a block or transaction, only errInvalid means the sender misbehaved
+var errDuplicate = errors.New("duplicate")
+var errConflict = errors.New("conflicts with chain")
+var errInvalid = errors.New("invalid")
+var errPolicy = errors.New("refused by mempool policy")
 
+// StartHandler answers the legacy /start request, the node is started by Start
+func (node *Node) StartHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "already started")
 }
 
 // Display peerList and sbc
-func Show(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, "%s\n%s", Peers.Show(), SBC.Show())
+func (node *Node) Show(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "%s\n%s", node.peers.Show(), node.sbc.Show())
 }
 
-// Download blockchain from TA server
-func Download() {
-	peerMapJSON, _ := Peers.PeerMapToJson()
-	hbd := data.NewHeartBeatData(nodeID, false, "", false, "", peerMapJSON, selfAddr)
+// Download blockchain from a seed node
+func (node *Node) Download(seed string) error {
+	peerMapJSON, _ := node.peers.PeerMapToJson()
+	hbd := data.NewHeartBeatData(node.id, nil, peerMapJSON, node.getSelfAddr())
+	hbd.Sign(node.minerKey)
 	hbdJSON, _ := json.Marshal(hbd)
-	res, err := http.Post(bcDownloadServer, "application/json", bytes.NewBuffer(hbdJSON))
+	blockChain, observed, err := node.transport.Download(seed, hbdJSON)
 	if err != nil {
-		log.Fatal("Cannot download from first node")
+		return err
+	}
+	node.learnAddr(observed, seed)
+	if err := node.insertChain(blockChain); err != nil {
+		if err == errInvalid {
+			node.recordPeer(seed, data.ScoreInvalidBlock)
+		}
+		return err
 	}
+	return nil
+}
 
-	json, err := ioutil.ReadAll(res.Body)
-	res.Body.Close()
+// insertChain verifies and inserts the blocks of a chain in canonical binary encoding like announced blocks, so a
+// downloaded or restored chain cannot bring in blocks gossip would refuse. Blocks without parent, duplicates and
+// blocks conflicting with the chain are skipped, errInvalid is returned at the first invalid block and the blocks
+// before it stay inserted
+func (node *Node) insertChain(blockChain []byte) error {
+	blocks, err := p2.DecodeBlocks(blockChain)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	SBC.UpdateEntireBlockChain(string(json))
+	defer node.chainChanged()
+	for _, block := range blocks {
+		if !node.hasParent(block) {
+			continue
+		}
+		switch node.verifyBlock(block) {
+		case nil:
+			node.sbc.Insert(block)
+		case errInvalid:
+			return errInvalid
+		}
+	}
+	return nil
 }
 
-// Upload blockchain to whoever called this method, return jsonStr
-func Upload(w http.ResponseWriter, r *http.Request) {
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		log.Fatal(err)
+// Upload blockchain to whoever called this method in canonical binary encoding
+func (node *Node) Upload(w http.ResponseWriter, r *http.Request) {
+	body, ok := readBody(w, r)
+	if !ok {
+		return
 	}
-	hbd := new(data.HeartBeatData)
-	json.Unmarshal(body, &hbd)
-	Peers.Add(hbd.Addr, hbd.Id)
-	blockChainJSON, err := SBC.BlockChainToJson()
-	if err != nil {
-		log.Fatal(err)
+	hbd, status := node.acceptHeartBeat(body)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
 	}
-	fmt.Fprint(w, blockChainJSON)
+	setObservedAddr(w, r, hbd.Addr)
+	writeBinary(w, node.upload(hbd))
+}
+
+// upload adds the downloading node to the peers and returns the blockchain
+func (node *Node) upload(hbd *data.HeartBeatData) []byte {
+	node.peers.Add(hbd.Addr, hbd.Id)
+	node.peers.Seen(hbd.Addr)
+	return node.sbc.EncodeBlockChain()
 }
 
-// Upload a block to whoever called this method, return jsonStr
-func UploadBlock(w http.ResponseWriter, r *http.Request) {
+// Upload a block to whoever called this method, peers get the binary encoding and api clients json
+func (node *Node) UploadBlock(w http.ResponseWriter, r *http.Request) {
 	heightStr := strings.Split(r.URL.Path, "/")[2]
 	height, err := strconv.ParseInt(heightStr, 10, 32)
 	if err != nil {
@@ -131,109 +109,176 @@ func UploadBlock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	hash := strings.Split(r.URL.Path, "/")[3]
-	block, success := SBC.GetBlock(int32(height), hash)
-	if success {
-		fmt.Fprint(w, block.EncodeToJSON())
-	} else {
+	block, success := node.sbc.GetBlock(int32(height), hash)
+	if !success {
 		w.WriteHeader(http.StatusNoContent)
+	} else if acceptsBinary(r) {
+		writeBinary(w, block.Encode())
+	} else {
+		fmt.Fprint(w, block.EncodeToJSON())
 	}
 }
 
 // Received a heartbeat
-func HeartBeatReceive(w http.ResponseWriter, r *http.Request) {
-	if !ifStarted {
+func (node *Node) HeartBeatReceive(w http.ResponseWriter, r *http.Request) {
+	body, ok := readBody(w, r)
+	if !ok {
 		return
 	}
-
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		log.Fatal(err)
+	hbd, status := node.acceptHeartBeat(body)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
 		return
 	}
-	//Unmarshal json
+	setObservedAddr(w, r, hbd.Addr)
+	node.receiveHeartBeat(hbd)
+	//END OF HEARTBEAT
+}
+
+// acceptHeartBeat decodes and authenticates a heartbeat, the status tells the sender why it was refused. A peer is
+// refused if its key or the address it advertises is banned, later records for the address are charged to the key
+func (node *Node) acceptHeartBeat(body []byte) (*data.HeartBeatData, int) {
 	hbd := new(data.HeartBeatData)
 	json.Unmarshal(body, &hbd)
+	key, ok := node.authenticateHeartBeat(hbd)
+	if !ok {
+		return nil, http.StatusUnauthorized
+	}
+	if node.reputations.IsBanned(key) || node.reputations.IsBanned(hbd.Addr) {
+		return nil, http.StatusForbidden
+	}
+	node.reputations.Identify(hbd.Addr, key)
+	return hbd, http.StatusOK
+}
 
+// receiveHeartBeat merges the peers of an accepted heartbeat and downloads the announced inventory
+func (node *Node) receiveHeartBeat(hbd *data.HeartBeatData) {
 	//Add addresses to peer list
-	if hbd.Addr != selfAddr {
-		Peers.Add(hbd.Addr, hbd.Id)
+	if hbd.Addr != node.getSelfAddr() {
+		node.peers.Add(hbd.Addr, hbd.Id)
+		node.peers.Seen(hbd.Addr)
 	}
-	Peers.InjectPeerMapJson(hbd.PeerMapJson, selfAddr)
+	node.peers.InjectPeerMapJson(hbd.PeerMapJson, node.getSelfAddr())
+	node.dropBannedPeers()
 
-	if hbd.IfNewBlock {
-		block := new(p2.Block)
-		block.DecodeFromJson(hbd.BlockJson)
-		if !SBC.CheckParentHash(*block) {
-			AskForBlock(block.Header.Height-1, block.Header.ParentHash)
-		}
-		if verifyBlock(*block) {
-			SBC.Insert(*block)
-		}
-	} else if hbd.IfNewTransaction {
-		processNewTransaction(hbd.TransactionJson)
+	//Download announced items in the background so the sender is not kept waiting
+	if len(hbd.Inventory) > 0 {
+		node.goroutine(func() { node.handleInventory(hbd.Addr, hbd.Inventory) })
 	}
+}
 
-	if hbd.Hops > 0 {
-		ForwardHeartBeat(*hbd)
-	}
-	//END OF HEARTBEAT
+// authenticateHeartBeat checks the signature of the heartbeat and that its id belongs to the signing key, it
+// returns the canonical encoding of the key
+func (node *Node) authenticateHeartBeat(hbd *data.HeartBeatData) (string, bool) {
+	if !hbd.Verify() {
+		fmt.Printf("Received unsigned or mis-signed heartbeat from %v, ignored\n", hbd.Addr)
+		return "", false
+	}
+	if !hbd.Fresh(time.Duration(node.config.Network.HeartBeatMaxAge)) {
+		fmt.Printf("Received stale heartbeat from %v, ignored\n", hbd.Addr)
+		return "", false
+	}
+	//Verify decoded the key already
+	publicKey, _ := tx.DecodeECDSAPublicKey(hbd.PublicKey)
+	if hbd.Id != data.NodeIdFromKey(publicKey) {
+		fmt.Printf("Received heartbeat from %v with id %d not derived from its key, ignored\n", hbd.Addr, hbd.Id)
+		return "", false
+	}
+	key := tx.EncodeECDSAPublicKey(publicKey)
+	if !node.peers.Bind(hbd.Id, key) {
+		fmt.Printf("Received heartbeat from %v with id %d colliding with another key, ignored\n", hbd.Addr, hbd.Id)
+		return "", false
+	}
+	return key, true
 }
 
-// Ask another server to return a block of certain height and hash
-func AskForBlock(height int32, hash string) {
-	pm := Peers.Copy()
+// Ask another server to return a block of certain height and hash, returns true if the block and its ancestors are in the chain afterwards
+func (node *Node) AskForBlock(height int32, hash string) bool {
+	if _, ok := node.sbc.GetBlock(height, hash); ok {
+		return true
+	}
+	pm := node.peers.Copy()
 	for k := range pm {
-		url := k + "/block/" + string(height) + "/" + hash
-		res, err := http.Get(url)
+		if !node.peers.Due(k) {
+			continue
+		}
+		block, err := node.fetchBlock(k, height, hash)
+		if err != nil && err != errNotFound {
+			//If encounter network erros, move on to next peer to ask for block
+			node.peerFailed(k)
+			continue
+		}
+		node.peers.Seen(k)
 		if err != nil {
-			//If encounter http erros, move on to next peer to ask for block
 			continue
 		}
-		defer res.Body.Close()
-		if res.StatusCode == 200 {
-			json, err := ioutil.ReadAll(res.Body)
-			res.Body.Close()
-			if err != nil {
-				log.Fatal(err)
-			}
-			block := new(p2.Block)
-			block.DecodeFromJson(string(json))
-			SBC.Insert(*block)
-			if !SBC.CheckParentHash(*block) {
-				AskForBlock(block.Header.Height-1, block.Header.ParentHash)
-			}
-			return //Return as soon as the block is found
+		//Fetch the missing ancestors first so the block can be checked against the finalized chain,
+		//a block whose ancestors cannot be fetched is not inserted so that no orphan is left behind
+		if !node.hasParent(block) && !node.AskForBlock(block.Header.Height-1, block.Header.ParentHash) {
+			return false
+		}
+		//Ancestors are checked like announced blocks, a peer must not get a block into the chain by sending an
+		//unverified parent
+		switch node.verifyBlock(block) {
+		case nil:
+		case errDuplicate:
+			return true
+		case errInvalid:
+			node.recordPeer(k, data.ScoreInvalidBlock)
+			return false
+		default:
+			return false
 		}
+		if !node.insertBlock(block) {
+			fmt.Printf("Received block %v conflicting with finalized chain, ignored\n", block.Header.Hash)
+			return false
+		}
+		return true //Return as soon as the block is found
 	}
+	return false
 }
 
-func ForwardHeartBeat(heartBeatData data.HeartBeatData) {
-	Peers.Rebalance()
-	peerMapJSON, _ := Peers.PeerMapToJson()
-	heartBeatData.PeerMapJson = peerMapJSON
-	heartBeatData.Addr = selfAddr
-	heartBeatData.Id = nodeID
-	heartBeatData.Hops--
-	hbdJSON, _ := json.Marshal(heartBeatData)
+// hasParent returns true if the parent of the block is in the chain, the first block has no parent
+func (node *Node) hasParent(block p2.Block) bool {
+	return block.Header.Height <= 1 || node.sbc.CheckParentHash(block)
+}
 
-	pm := Peers.Copy()
-	for k := range pm {
-		http.Post(k+"/heartbeat/receive", "application/json", bytes.NewBuffer(hbdJSON))
+// sendHeartBeat posts the heartbeat to a peer, peers that do not answer lose score and are retried with backoff
+func (node *Node) sendHeartBeat(addr string, hbdJSON []byte) {
+	if !node.peers.Due(addr) {
+		return
 	}
+	observed, err := node.transport.SendHeartBeat(addr, hbdJSON)
+	if err != nil {
+		node.peerFailed(addr)
+		return
+	}
+	node.learnAddr(observed, addr)
+	node.peers.Seen(addr)
 }
 
-func Canonical(w http.ResponseWriter, r *http.Request) {
-	latestBlocks, _ := SBC.GetLatestBlocks()
+// peerFailed records a peer that could not be reached
+func (node *Node) peerFailed(addr string) {
+	node.recordPeer(addr, data.ScoreTimeout)
+	if node.peers.Failed(addr) {
+		fmt.Printf("Evicted unresponsive peer %v\n", addr)
+	}
+}
+
+func (node *Node) Canonical(w http.ResponseWriter, r *http.Request) {
+	latestBlocks, _ := node.sbc.GetLatestBlocks()
+	finalizedHeight, finalizedHash := node.sbc.Finalized()
+	fmt.Fprintf(w, "Finalized: height %d, hash %s\n\n", finalizedHeight, finalizedHash)
 
 	for i, b := range latestBlocks {
 		fmt.Fprintf(w, "Chain #%d: \n\n", i)
 		temp := b
-		height := SBC.Len()
+		height := node.sbc.Len()
 		for true {
-			fmt.Fprintf(w, "Height: %d, block: %s\n\n", height, temp.EncodeToJSON())
+			fmt.Fprintf(w, "Height: %d, confirmations: %d, finalized: %v, block: %s\n\n", height, node.sbc.Len()-height+1, height <= finalizedHeight, temp.EncodeToJSON())
 			height--
 			var err error
-			temp, err = SBC.GetParentBlock(temp)
+			temp, err = node.sbc.GetParentBlock(temp)
 			if err != nil {
 				break
 			}
@@ -242,87 +287,153 @@ func Canonical(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func ViewTransactions(w http.ResponseWriter, r *http.Request) {
-	transactions := SBC.Transactions()
+// ViewTransactions lists the transactions of the canonical chain, ?address= keeps those sent by the account
+func (node *Node) ViewTransactions(w http.ResponseWriter, r *http.Request) {
+	address, ok := addressQuery(w, r)
+	if !ok {
+		return
+	}
+	transactions := make([]api.ChainTransaction, 0)
+	for _, t := range node.canonicalTransactions() {
+		if address == "" || t.FromAddress == address {
+			transactions = append(transactions, t)
+		}
+	}
 	json, _ := json.MarshalIndent(transactions, "", "\t")
 	fmt.Fprintln(w, string(json))
 }
 
-func ViewMerits(w http.ResponseWriter, r *http.Request) {
-	transactions := SBC.Transactions()
-	merits := make([]models.SignedMerit, 0)
-	for _, t := range transactions {
-		if t.TXType == "application" {
-			sm := new(models.SignedMerit)
-			json.Unmarshal([]byte(t.Payload), &sm)
-			merits = append(merits, *sm)
+// ViewMerits lists the merits of the canonical chain, ?address= keeps those published by the account
+func (node *Node) ViewMerits(w http.ResponseWriter, r *http.Request) {
+	address, ok := addressQuery(w, r)
+	if !ok {
+		return
+	}
+	merits := make([]api.ChainMerit, 0)
+	for _, m := range node.canonicalMerits() {
+		if address == "" || m.Address == address {
+			merits = append(merits, m)
 		}
 	}
 	json, _ := json.MarshalIndent(merits, "", "\t")
 	fmt.Fprintln(w, string(json))
 }
 
-func MinerBalance(w http.ResponseWriter, r *http.Request) {
-	canonical, _ := SBC.Canonical(0)
-	balancemap := make(map[string]float32)
+// MinerBalance lists the fees credited to every producer address, ?address= keeps the balance of the account
+func (node *Node) MinerBalance(w http.ResponseWriter, r *http.Request) {
+	address, ok := addressQuery(w, r)
+	if !ok {
+		return
+	}
+	canonical, _ := node.sbc.Canonical(0)
+	finalizedHeight, _ := node.sbc.Finalized()
+	balancemap := make(map[string]*api.MinerBalanceData)
 	for _, b := range canonical {
 		producer := b.Header.Producer
+		if address != "" && producer != address {
+			continue
+		}
 		txtotal := float32(0)
-		t := new(tx.Transaction)
-		for _, v := range b.Value.Mapping {
-			json.Unmarshal([]byte(v), &t)
+		txs, _ := b.Transactions()
+		for _, t := range txs {
 			txtotal += t.TXFee
 		}
-		if balance, ok := balancemap[producer]; ok {
-			balancemap[producer] = balance + txtotal
-		} else {
-			balancemap[producer] = txtotal
+		balance, ok := balancemap[producer]
+		if !ok {
+			balance = new(api.MinerBalanceData)
+			balancemap[producer] = balance
+		}
+		balance.Balance += txtotal
+		if b.Header.Height <= finalizedHeight {
+			balance.FinalizedBalance += txtotal
 		}
 	}
 	outputbytes, _ := json.MarshalIndent(balancemap, "", "\t")
 	fmt.Fprintln(w, string(outputbytes))
 }
 
-func ReceiveTransaction(w http.ResponseWriter, r *http.Request) {
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		log.Fatal(err)
+// addressQuery returns the address a query is restricted to, "" if there is none. A malformed address is
+// answered with 400 and ok is false
+func addressQuery(w http.ResponseWriter, r *http.Request) (address string, ok bool) {
+	address = r.URL.Query().Get("address")
+	if address == "" {
+		return "", true
+	}
+	if err := tx.ValidateAddress(address); err != nil {
+		http.Error(w, "invalid address: "+err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return address, true
+}
+
+// ReceiveTransaction queues a transaction posted by a client, a malformed transaction is answered with 400
+func (node *Node) ReceiveTransaction(w http.ResponseWriter, r *http.Request) {
+	body, ok := readBody(w, r)
+	if !ok {
 		return
 	}
 
-	processNewTransaction(string(body))
-	hbd := new(data.HeartBeatData)
-	hbd.IfNewTransaction = true
-	hbd.TransactionJson = string(body)
-	hbd.Hops = 2
-	ForwardHeartBeat(*hbd)
+	t := new(tx.Transaction)
+	if err := t.DecodeFromJSON(string(body)); err != nil {
+		http.Error(w, "malformed transaction: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := node.SubmitTransaction(*t); err != nil {
+		http.Error(w, err.Error(), transactionStatus(err))
+	}
 }
 
-func processNewTransaction(transactionJSON string) {
-	t := new(tx.Transaction)
-	t.DecodeFromJSON(string(transactionJSON))
+// transactionStatus returns the http status telling a client why its transaction was refused
+func transactionStatus(err error) int {
+	switch err {
+	case errInvalid:
+		return http.StatusBadRequest
+	case errDuplicate, errConflict:
+		return http.StatusConflict
+	}
+	return http.StatusUnprocessableEntity
+}
 
-	if TransactionQueue.Contains(*t) {
+// SubmitTransaction queues a transaction submitted by a client and announces it to the peers
+func (node *Node) SubmitTransaction(t tx.Transaction) error {
+	if err := node.processNewTransaction(t); err != nil {
+		return err
+	}
+	node.announceTransaction(t, "")
+	return nil
+}
+
+// processNewTransaction queues a valid transaction, it returns the reason if the transaction was not queued
+func (node *Node) processNewTransaction(t tx.Transaction) error {
+	if node.mempool.Contains(t) {
 		fmt.Printf("Ignored duplicate transaction %v\n", t.Hash)
-		return
+		return errDuplicate
 	}
 
-	if verifyTransaction(*t) {
-		fmt.Printf("Received valid transaction %v\n", t.Hash)
-		item := &data.Item{Value: *t, Priority: t.TXFee}
-		heap.Push(&TransactionQueue, item)
-	} else {
-		fmt.Println("Received invalid transaction, ignored")
+	if t.TXFee < node.config.Mempool.MinFee || node.mempool.Len() >= node.config.Mempool.MaxSize {
+		fmt.Printf("Received transaction %v that is %v, ignored\n", t.Hash, errPolicy)
+		return errPolicy
+	}
 
+	if err := node.verifyTransaction(t); err != nil {
+		fmt.Printf("Received transaction %v that is %v, ignored\n", t.Hash, err)
+		return err
 	}
+	fmt.Printf("Received valid transaction %v\n", t.Hash)
+	node.mempool.Push(t)
+	node.transactionQueued(t)
+	return nil
 }
 
-func pullTransactions(size int) []tx.Transaction {
+func (node *Node) pullTransactions(size int) []tx.Transaction {
 	txs := make([]tx.Transaction, 0)
 	i := 0
-	for ; i < size && TransactionQueue.Len() > 0; i++ {
-		t := heap.Pop(&TransactionQueue).(*data.Item).Value
-		if !SBC.ContainsTransaction(t) {
+	for ; i < size; i++ {
+		t, ok := node.mempool.Pop()
+		if !ok {
+			break
+		}
+		if !node.sbc.ContainsTransaction(t) {
 			txs = append(txs, t)
 		} else {
 			i--
@@ -345,175 +456,215 @@ func genHex(n int) string {
 	return hex.EncodeToString(bytes)
 }
 
-func verifyTransaction(tx tx.Transaction) bool {
+// verifyTransaction returns errInvalid for malformed transactions and errConflict if the transaction does not fit the chain
+func (node *Node) verifyTransaction(tx tx.Transaction) error {
 	//Tx has correct hash & signature
 	if !tx.Verify() {
-		return false
+		return errInvalid
+	}
+
+	//Tx version is active for the next block
+	if tx.Version > node.sbc.Schedule().At(node.sbc.Len()+1).TxVersion {
+		return errConflict
 	}
 
 	//Tx is not in canonicalchain
-	if SBC.ContainsTransaction(tx) {
-		return false
+	if node.sbc.ContainsTransaction(tx) {
+		return errConflict
 	}
 
+	return verifyReferences(tx, node.sbc.Transactions())
+}
+
+// verifyReferences returns errConflict if the transaction refers to a merit or an acceptance missing from transactions
+func verifyReferences(transaction tx.Transaction, transactions []tx.Transaction) error {
 	//Make sure that if this is an acceptance, accepting non-existing merits is not valid
-	transactions := SBC.Transactions()
-	if tx.TXType == "acceptance" {
+	if transaction.TXType == tx.TYPE_ACCEPTANCE {
 		found := false
-		sm := new(models.SignedMerit)
 		for _, t := range transactions {
-			json.Unmarshal([]byte(t.Payload), &sm)
-			if sm.Hash == tx.To {
+			if t.TXType != tx.TYPE_APPLICATION {
+				continue
+			}
+			payload, err := t.DecodePayload()
+			if err != nil {
+				continue
+			}
+			if payload.(*tx.ApplicationPayload).Hash == transaction.To {
 				found = true
 				break
 			}
 		}
 		if !found {
-			return false
+			return errConflict
 		}
 	}
 
 	//Make sure that if this is a confirmation, this is confirming on a existing acceptance
-	if tx.TXType == "confimation" {
+	if transaction.TXType == tx.TYPE_CONFIRMATION {
 		found := false
 		for _, t := range transactions {
-			if t.Hash == tx.To {
+			if t.Hash == transaction.To && t.TXType == tx.TYPE_ACCEPTANCE {
 				found = true
 				break
 			}
 		}
 		if !found {
-			return false
+			return errConflict
 		}
 	}
 
-	return true
+	return nil
 }
 
-func verifyBlock(block p2.Block) bool {
-	sum := sha3.Sum256([]byte(block.Header.ParentHash + block.Header.Nonce + block.Value.Root))
-	hash := hex.EncodeToString(sum[:])
-	if !strings.HasPrefix(hash, DIFFICULTY) {
-		return false
+// verifyBlock returns nil if the block can be inserted, otherwise the reason for refusing it
+func (node *Node) verifyBlock(block p2.Block) error {
+	if !node.engine.VerifySeal(block) {
+		return errInvalid
 	}
 
-	if len(block.Value.Mapping) > 20 {
+	if len(block.Value.Mapping) > node.config.Mining.BlockSize {
 		fmt.Printf("Received invalid block %v (invalid block size)\n", block.Header.Hash)
-		return false
+		return errInvalid
 	}
 
-	if SBC.ContainsBlock(block) {
+	if err := tx.ValidateAddress(block.Header.Producer); err != nil {
+		fmt.Printf("Received invalid block %v (producer %v)\n", block.Header.Hash, err)
+		return errInvalid
+	}
+
+	if node.sbc.ContainsBlock(block) {
 		fmt.Printf("Received existing block %v, ignored\n", block.Header.Hash)
-		return false
+		return errDuplicate
 	}
 
-	for k := range block.Value.Mapping {
-		t := new(tx.Transaction)
-		tjson, _ := block.Value.Get(k)
-		t.DecodeFromJSON(tjson)
-		if !verifyTransaction(*t) {
-			return false
+	if !node.sbc.ExtendsFinalized(block) {
+		fmt.Printf("Received block %v conflicting with finalized chain, ignored\n", block.Header.Hash)
+		return errConflict
+	}
+
+	//The transactions are checked against the chain the block extends, not against our canonical chain,
+	//a block of a competing fork may hold transactions that are already mined on ours
+	ancestors := node.sbc.AncestorTransactions(block)
+	mined := make(map[string]bool)
+	for _, t := range ancestors {
+		mined[t.Hash] = true
+	}
+	//The block and its transactions are checked by the rules of their versions
+	txs, err := block.Verify()
+	if err == nil {
+		err = node.sbc.Schedule().VerifyVersions(block, txs)
+	}
+	if err != nil {
+		fmt.Printf("Received invalid block %v (%v)\n", block.Header.Hash, err)
+		return errInvalid
+	}
+	for _, t := range txs {
+		if !t.Verify() {
+			return errInvalid
+		}
+		if mined[t.Hash] {
+			return errConflict
+		}
+		if err := verifyReferences(t, ancestors); err != nil {
+			return err
 		}
 	}
 	fmt.Printf("Received valid block %v\n", block.Header.Hash)
-	return true
+	return nil
 }
 
-func StartHeartBeat() {
+// StartHeartBeat sends heartbeats to the peers until the node is stopped
+func (node *Node) StartHeartBeat() {
 	for true {
-		time.Sleep(time.Duration(rand.Intn(5)+5) * time.Second)
-		Peers.Rebalance()
-		peerMapJSON, _ := Peers.PeerMapToJson()
-		pm := Peers.Copy()
-		hbd := data.PrepareHeartBeatData(&SBC, nodeID, peerMapJSON, selfAddr)
+		//Jitter the interval so that the peers do not send their heartbeats in lockstep
+		interval := time.Duration(node.config.Network.HeartBeatInterval)
+		if !node.sleep(interval + time.Duration(rand.Int63n(int64(interval)))) {
+			return
+		}
+		node.peers.Rebalance()
+		peerMapJSON, _ := node.peers.PeerMapToJson()
+		pm := node.peers.Copy()
+		hbd := data.PrepareHeartBeatData(&node.sbc, node.id, peerMapJSON, node.getSelfAddr())
+		hbd.Sign(node.minerKey)
 		hbdJSON, _ := json.Marshal(hbd)
 		for k := range pm {
-			http.Post(k+"/heartbeat/receive", "application/json", bytes.NewBuffer(hbdJSON))
+			node.sendHeartBeat(k, hbdJSON)
 		}
+		node.savePeers()
 	}
 }
 
-func StartTryingNonces() {
+// StartTryingNonces mines blocks until the node is stopped, the pulled transactions are then released back into the mempool
+func (node *Node) StartTryingNonces() {
 	//Seed the rand module
 	rand.Seed(time.Now().UTC().UnixNano())
-	nonce := genHex(16)
-	prevHeight := SBC.Len()
-	txs := pullTransactions(20)
-	mpt := new(p1.MerklePatriciaTrie)
-	mpt.Initial()
-	for _, t := range txs {
-		// MPT<TransactionHash, Transaction>
-		tjson, _ := t.EncodeToJSON()
-		mpt.Insert(t.Hash, string(tjson))
-	}
-	for true {
+	prevHeight := node.sbc.Len()
+	txs := node.pullTransactions(node.config.Mining.BlockSize)
+	defer func() { node.releaseTransactions(txs) }()
+	var candidate *p2.Block
+	for node.ctx.Err() == nil {
 		// If no transaction was pulled from tx list, sleep for few seconds and retry
 		if len(txs) == 0 {
 			fmt.Printf("Transaction Queue is empty, listening for transactions\n")
-			time.Sleep(time.Duration(7) * time.Second)
-			txs = pullTransactions(20)
-			mpt.Initial()
-			for _, t := range txs {
-				// MPT<TransactionHash, Transaction>
-				tjson, _ := t.EncodeToJSON()
-				mpt.Insert(t.Hash, string(tjson))
+			if !node.sleep(time.Duration(node.config.Mempool.EmptyWait)) {
+				return
 			}
+			txs = node.pullTransactions(node.config.Mining.BlockSize)
+			candidate = nil
 			if len(txs) != 0 {
 				fmt.Printf("Building block with %d transactions...\n", len(txs))
 			}
 			continue
 		}
 
-		//New block has arrived, release txs back into transaction queue and re-pull 20 transactions and make sure they are not in chain
-		if prevHeight != SBC.Len() {
-			prevHeight = SBC.Len()
-			for _, t := range txs {
-				if !SBC.ContainsTransaction(t) {
-					item := &data.Item{Value: t, Priority: t.TXFee}
-					heap.Push(&TransactionQueue, item)
-				}
-			}
-			txs = pullTransactions(20)
+		//New block has arrived, release txs back into transaction queue and re-pull a block worth of transactions and make sure they are not in chain
+		if prevHeight != node.sbc.Len() {
+			prevHeight = node.sbc.Len()
+			node.releaseTransactions(txs)
+			txs = node.pullTransactions(node.config.Mining.BlockSize)
+			candidate = nil
 			if len(txs) == 0 {
 				continue
 			}
-			mpt.Initial()
-			for _, t := range txs {
-				// MPT<TransactionHash, Transaction>
-				tjson, _ := t.EncodeToJSON()
-				mpt.Insert(t.Hash, string(tjson))
-			}
 			fmt.Printf("Building block with %d transactions...\n", len(txs))
 		}
-		//Now its safe to start testing this nonce
-		parentBlocks, err := SBC.GetLatestBlocks()
-		var parentBlockHash string
-		if err != nil {
-			parentBlockHash = "Genesis"
-		} else {
-			parentBlock := parentBlocks[0]
-			parentBlockHash = parentBlock.Header.Hash
-		}
-		hashStr := parentBlockHash + nonce + mpt.Root
-		sum := sha3.Sum256([]byte(hashStr))
-		hash := hex.EncodeToString(sum[:])
-		if strings.HasPrefix(hash, DIFFICULTY) {
-			fmt.Println("FOUND NONCE: " + nonce + " Hash: " + hash)
-			block := SBC.GenBlock(*mpt, nonce, tx.EncodeECDSAPublicKey(&minerPrivateKey.PublicKey))
+
+		//Build the candidate block on top of the tip chosen by the consensus engine
+		if candidate == nil {
+			block := node.genCandidateBlock(txs)
+			candidate = &block
+		}
+		if node.engine.Seal(candidate) {
+			block := *candidate
+			node.insertBlock(block)
 			fmt.Println("Generated block " + block.Header.Hash)
-			hbd := new(data.HeartBeatData)
-			hbd.IfNewBlock = true
-			hbd.BlockJson = block.EncodeToJSON()
-			hbd.Hops = 2
-			ForwardHeartBeat(*hbd)
-			txs = pullTransactions(20)
-			mpt.Initial()
-			for _, t := range txs {
-				// MPT<TransactionHash, Transaction>
-				tjson, _ := t.EncodeToJSON()
-				mpt.Insert(t.Hash, string(tjson))
-			}
+			node.announceBlock(block, "")
+			txs = node.pullTransactions(node.config.Mining.BlockSize)
+			candidate = nil
+		}
+	}
+}
+
+// releaseTransactions puts pulled transactions that did not make it into the chain back into the mempool
+func (node *Node) releaseTransactions(txs []tx.Transaction) {
+	for _, t := range txs {
+		if !node.sbc.ContainsTransaction(t) {
+			node.mempool.Push(t)
 		}
-		nonce = genHex(16)
 	}
 }
+
+func (node *Node) genCandidateBlock(txs []tx.Transaction) p2.Block {
+	mpt := new(p1.MerklePatriciaTrie)
+	mpt.Initial()
+	for _, t := range txs {
+		// MPT<TransactionHash, Transaction>
+		mpt.Insert(t.Hash, string(t.Encode()))
+	}
+	var parent *p2.Block
+	if parentBlocks, err := node.sbc.GetLatestBlocks(); err == nil {
+		tip := node.engine.SelectTip(parentBlocks)
+		parent = &tip
+	}
+	return node.sbc.GenBlock(parent, *mpt, node.producer())
+}
@@ -0,0 +1,105 @@
+package p3
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+
+	"../api"
+	"../transaction"
+)
+
+// loadMinerIdentity loads the miner key, generating and saving one if the file does not exist yet.
+// The miner key is also the node's long-term key used to sign heartbeats, the optional payout key is credited for mined blocks
+func (node *Node) loadMinerIdentity() error {
+	var err error
+	minerKeyFile := node.config.path(node.config.Mining.MinerKeyFile)
+	payoutKeyFile := node.config.path(node.config.Mining.PayoutKeyFile)
+	if minerKeyFile == "" {
+		fmt.Println("No miner key file given, fees mined by this node will be lost on restart")
+		node.minerKey, err = ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+		if err != nil {
+			return err
+		}
+	} else {
+		node.minerKey, err = readOrCreateKey(minerKeyFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	node.payoutKey = &node.minerKey.PublicKey
+	if payoutKeyFile != "" {
+		pemBytes, err := ioutil.ReadFile(payoutKeyFile)
+		if err != nil {
+			return err
+		}
+		node.payoutKey, err = tx.DecodeECDSAPublicKeyPEM(pemBytes)
+		if err != nil {
+			return fmt.Errorf("cannot read payout key %v: %v", payoutKeyFile, err)
+		}
+	}
+	return nil
+}
+
+func readOrCreateKey(keyfile string) (*ecdsa.PrivateKey, error) {
+	pemBytes, err := ioutil.ReadFile(keyfile)
+	if err == nil {
+		privateKey, err := tx.DecodeECDSAPrivateKey(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read key %v: %v", keyfile, err)
+		}
+		return privateKey, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	pemBytes, err = tx.EncodeECDSAPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(keyfile, pemBytes, 0600); err != nil {
+		return nil, err
+	}
+	log.Printf("Generated new key %v", keyfile)
+	return privateKey, nil
+}
+
+// producer returns the address that is credited for the blocks mined by this node
+func (node *Node) producer() string {
+	return tx.Address(node.payoutKey)
+}
+
+// Display the producer identity of this node
+func (node *Node) NodeInfo(w http.ResponseWriter, r *http.Request) {
+	output, _ := json.MarshalIndent(node.Info(), "", "\t")
+	fmt.Fprintln(w, string(output))
+}
+
+// Info describes the identity and chain height of this node
+func (node *Node) Info() api.NodeInfoData {
+	finalizedHeight, finalizedHash := node.sbc.Finalized()
+	return api.NodeInfoData{
+		Id:              node.id,
+		Addr:            node.getSelfAddr(),
+		Signer:          tx.EncodeECDSAPublicKey(&node.minerKey.PublicKey),
+		Producer:        node.producer(),
+		Height:          node.sbc.Len(),
+		FinalizedHeight: finalizedHeight,
+		FinalizedHash:   finalizedHash,
+		ChainHash:       node.sbc.ShowHash(),
+		Bootstrapped:    node.bootstrapped.Load(),
+		Peers:           len(node.peers.Copy()),
+	}
+}
@@ -11,7 +11,7 @@ func Logger(inner http.Handler, name string) http.Handler {
 		start := time.Now()
 
 		inner.ServeHTTP(w, r)
-		if name != "HeartBeatReceive" && name != "UploadBlock" {
+		if name != "HeartBeatReceive" && name != "UploadBlock" && name != "UploadTransaction" {
 			log.Printf(
 				"%s\t%s\t%s\t%s",
 				r.Method,
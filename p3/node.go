@@ -0,0 +1,275 @@
+package p3
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"../transaction"
+	"./data"
+)
+
+// Node is a running jobmarket node, it owns the blockchain, the mempool, the peers and the goroutines working on them
+type Node struct {
+	config      Config
+	id          int32
+	sbc         data.SyncBlockChain
+	peers       data.PeerList
+	mempool     *data.Mempool
+	events      *eventHub
+	seen        *data.SeenCache
+	reputations *data.Reputation
+	engine      Consensus
+	minerKey    *ecdsa.PrivateKey
+	payoutKey   *ecdsa.PublicKey
+
+	selfAddr     string
+	addrMux      sync.Mutex
+	addrLearned  bool                       // selfAddr was replaced by an address observed by the peers
+	observations map[string]map[string]bool // observed address of this node -> peers that reported it
+
+	transport Transport
+
+	bootstrapped atomic.Bool // the chain was downloaded from a seed or a new network was started, mining runs
+
+	server  *http.Server
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	stopMux sync.Mutex // orders the cancel of Stop before the work started in the background
+}
+
+// NewNode loads the identity of the node and prepares its state, nothing runs until Start is called
+func NewNode(config Config) (*Node, error) {
+	node := &Node{config: config}
+	if config.Storage.DataDir != "" {
+		if err := os.MkdirAll(config.Storage.DataDir, 0700); err != nil {
+			return nil, err
+		}
+	}
+	if err := node.loadMinerIdentity(); err != nil {
+		return nil, err
+	}
+	//The node id is derived from the node key so that it cannot collide by accident or be claimed by another node
+	node.id = data.NodeIdFromKey(&node.minerKey.PublicKey)
+	var err error
+	if node.reputations, err = data.NewReputation(config.Network.BanThreshold, time.Duration(config.Network.BanDuration), config.path(config.Storage.BanFile)); err != nil {
+		return nil, err
+	}
+	node.initSelfAddr()
+	if node.transport, err = node.newTransport(); err != nil {
+		return nil, err
+	}
+	node.sbc = data.NewBlockChain()
+	node.sbc.SetFinalityDepth(config.Mining.FinalityDepth)
+	node.sbc.SetSchedule(config.Mining.Upgrades)
+	//The engine checks seals against the chain, so it is created after it
+	if node.engine, err = node.newConsensus(); err != nil {
+		return nil, err
+	}
+	node.peers = data.NewPeerList(node.id, config.Network.MaxPeers)
+	node.peers.SetLiveness(data.Liveness{
+		MaxFailures: config.Network.MaxFailures,
+		Backoff:     time.Duration(config.Network.RetryBackoff),
+		MaxBackoff:  data.DefaultLiveness.MaxBackoff,
+	})
+	node.peers.Bind(node.id, tx.EncodeECDSAPublicKey(&node.minerKey.PublicKey))
+	node.seen = data.NewSeenCache(time.Duration(config.Network.SeenTTL))
+	node.mempool = data.NewMempool()
+	node.events = newEventHub()
+	node.ctx, node.cancel = context.WithCancel(context.Background())
+	node.reportStorage()
+	return node, nil
+}
+
+// reportStorage tells which state is lost when the node stops because no file is configured for it
+func (node *Node) reportStorage() {
+	storage := node.config.Storage
+	for _, file := range []struct{ name, what string }{
+		{storage.ChainFile, "blockchain"},
+		{storage.MempoolFile, "mempool"},
+		{storage.PeersFile, "peer list"},
+		{storage.BanFile, "ban list"},
+	} {
+		if file.name == "" {
+			fmt.Printf("No %v file given, the %v will not be persisted\n", file.what, file.what)
+		}
+	}
+}
+
+// Start listens on the configured interface and port, restores the persisted state and starts gossiping and mining.
+// The node stops its background work when ctx is cancelled, Stop has to be called to drain and persist
+func (node *Node) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", net.JoinHostPort(node.config.API.Bind, node.config.API.Port))
+	if err != nil {
+		return err
+	}
+	node.server = &http.Server{Handler: node.Handler()}
+	go func() {
+		if err := node.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("Server stopped: %v", err)
+		}
+	}()
+	fmt.Printf("Node %v listening on %v, advertised as %v\n", node.id, listener.Addr(), node.getSelfAddr())
+	node.Run(ctx)
+	return nil
+}
+
+// SetTransport replaces the transport used to reach peers, e.g. by one going through an in-process network simulator.
+// It has to be called before Run
+func (node *Node) SetTransport(transport Transport) {
+	node.transport.Close()
+	node.transport = transport
+}
+
+// Handler returns the http handler serving the endpoints of the node
+func (node *Node) Handler() http.Handler {
+	return NewRouter(node)
+}
+
+// Run starts the background work of the node without a listener, for nodes served by another server such as
+// an httptest server. That server has to be closed before Stop is called
+func (node *Node) Run(ctx context.Context) {
+	go func() {
+		select {
+		case <-ctx.Done():
+			node.cancel()
+		case <-node.ctx.Done():
+		}
+	}()
+	node.loadChain()
+	node.loadPeers()
+	node.loadMempool()
+	node.goroutine(node.StartHeartBeat)
+	node.goroutine(node.Bootstrap)
+}
+
+// Stop stops mining and gossiping, drains the in-flight requests and persists the chain, the mempool and the peers
+func (node *Node) Stop() error {
+	//Nothing is added to wg once the node is cancelled, so wg.Wait below cannot miss work started concurrently
+	node.stopMux.Lock()
+	node.cancel()
+	node.stopMux.Unlock()
+	var err error
+	if node.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(node.config.API.ShutdownTimeout))
+		defer cancel()
+		err = node.server.Shutdown(ctx)
+	}
+	node.wg.Wait()
+	node.transport.Close()
+	node.saveChain()
+	node.saveMempool()
+	node.savePeers()
+	return err
+}
+
+// goroutine runs f in the background, Stop waits for it to return. f is not run once the node is stopping
+func (node *Node) goroutine(f func()) {
+	if !node.enter() {
+		return
+	}
+	go func() {
+		defer node.wg.Done()
+		f()
+	}()
+}
+
+// enter registers work Stop has to wait for, the caller calls wg.Done when it is finished. It returns false
+// without registering anything if the node is stopping
+func (node *Node) enter() bool {
+	node.stopMux.Lock()
+	defer node.stopMux.Unlock()
+	if node.ctx.Err() != nil {
+		return false
+	}
+	node.wg.Add(1)
+	return true
+}
+
+// sleep waits for d, it returns false if the node was stopped in the meantime
+func (node *Node) sleep(d time.Duration) bool {
+	select {
+	case <-node.ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// loadChain restores the blockchain persisted by a previous run
+func (node *Node) loadChain() {
+	bytes, ok := node.readStorage(node.config.Storage.ChainFile)
+	if !ok {
+		return
+	}
+	if err := node.insertChain(bytes); err != nil {
+		fmt.Printf("Cannot read blockchain: %v\n", err)
+		return
+	}
+	fmt.Printf("Restored blockchain of height %d\n", node.sbc.Len())
+}
+
+func (node *Node) saveChain() {
+	node.writeStorage(node.config.Storage.ChainFile, node.sbc.EncodeBlockChain())
+}
+
+// loadMempool queues the transactions persisted by a previous run again, they are verified against the restored chain
+func (node *Node) loadMempool() {
+	bytes, ok := node.readStorage(node.config.Storage.MempoolFile)
+	if !ok {
+		return
+	}
+	transactions, err := tx.DecodeTransactions(bytes)
+	if err != nil {
+		fmt.Printf("Cannot read mempool: %v\n", err)
+		return
+	}
+	for _, t := range transactions {
+		node.processNewTransaction(t)
+	}
+}
+
+func (node *Node) saveMempool() {
+	node.writeStorage(node.config.Storage.MempoolFile, tx.EncodeTransactions(node.mempool.Transactions()))
+}
+
+// readStorage reads a storage file, it returns false if the file is not configured or does not exist
+func (node *Node) readStorage(name string) ([]byte, bool) {
+	filename := node.config.path(name)
+	if filename == "" {
+		return nil, false
+	}
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("Cannot read %v: %v\n", filename, err)
+		}
+		return nil, false
+	}
+	return bytes, true
+}
+
+// writeStorage replaces a storage file atomically so that a crash does not leave it half written
+func (node *Node) writeStorage(name string, bytes []byte) {
+	filename := node.config.path(name)
+	if filename == "" {
+		return
+	}
+	if err := ioutil.WriteFile(filename+".tmp", bytes, 0644); err != nil {
+		fmt.Printf("Cannot write %v: %v\n", filename, err)
+		return
+	}
+	if err := os.Rename(filename+".tmp", filename); err != nil {
+		fmt.Printf("Cannot write %v: %v\n", filename, err)
+	}
+}
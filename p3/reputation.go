@@ -0,0 +1,38 @@
+package p3
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// recordPeer applies delta to the score of a peer and drops the peer from the peer list once it is banned
+func (node *Node) recordPeer(addr string, delta int32) {
+	if addr == "" || addr == node.getSelfAddr() {
+		return
+	}
+	if node.reputations.Record(addr, delta) {
+		fmt.Printf("Banned peer %v for %v\n", addr, time.Duration(node.config.Network.BanDuration))
+		node.peers.Delete(addr)
+	}
+}
+
+// dropBannedPeers removes banned peers that were injected through another peer's peer map
+func (node *Node) dropBannedPeers() {
+	for addr := range node.peers.Copy() {
+		if node.reputations.IsBanned(addr) {
+			node.peers.Delete(addr)
+		}
+	}
+}
+
+// Display the score and ban status of known peers
+func (node *Node) ViewPeers(w http.ResponseWriter, r *http.Request) {
+	addrs := make([]string, 0)
+	for addr := range node.peers.Copy() {
+		addrs = append(addrs, addr)
+	}
+	output, _ := json.MarshalIndent(node.reputations.Show(addrs), "", "\t")
+	fmt.Fprintln(w, string(output))
+}
@@ -11,65 +11,98 @@ type Route struct {
 
 type Routes []Route
 
-var routes = Routes{
-	Route{
-		"Show",
-		"GET",
-		"/show",
-		Show,
-	},
-	Route{
-		"Upload",
-		"POST",
-		"/upload",
-		Upload,
-	},
-	Route{
-		"UploadBlock",
-		"GET",
-		"/block/{height}/{hash}",
-		UploadBlock,
-	},
-	Route{
-		"HeartBeatReceive",
-		"POST",
-		"/heartbeat/receive",
-		HeartBeatReceive,
-	},
-	Route{
-		"Start",
-		"GET",
-		"/start",
-		Start,
-	},
-	Route{
-		"Canonical",
-		"GET",
-		"/canonical",
-		Canonical,
-	},
-	Route{
-		"Receive Transaction",
-		"POST",
-		"/transaction",
-		ReceiveTransaction,
-	},
-	Route{
-		"View Merits",
-		"GET",
-		"/merits",
-		ViewMerits,
-	},
-	Route{
-		"View Transactions",
-		"GET",
-		"/transactions",
-		ViewTransactions,
-	},
-	Route{
-		"MinerBalance",
-		"GET",
-		"/balance",
-		MinerBalance,
-	},
+// routes returns the endpoints of the node
+func (node *Node) routes() Routes {
+	return Routes{
+		Route{
+			"Show",
+			"GET",
+			"/show",
+			node.Show,
+		},
+		Route{
+			"Upload",
+			"POST",
+			"/upload",
+			node.Upload,
+		},
+		Route{
+			"UploadBlock",
+			"GET",
+			"/block/{height}/{hash}",
+			node.UploadBlock,
+		},
+		Route{
+			"UploadTransaction",
+			"GET",
+			"/transaction/{hash}",
+			node.UploadTransaction,
+		},
+		Route{
+			"PeerSocket",
+			"GET",
+			"/peer/socket",
+			node.PeerSocket,
+		},
+		Route{
+			"HeartBeatReceive",
+			"POST",
+			"/heartbeat/receive",
+			node.HeartBeatReceive,
+		},
+		Route{
+			"Start",
+			"GET",
+			"/start",
+			node.StartHandler,
+		},
+		Route{
+			"Canonical",
+			"GET",
+			"/canonical",
+			node.Canonical,
+		},
+		Route{
+			"Receive Transaction",
+			"POST",
+			"/transaction",
+			node.ReceiveTransaction,
+		},
+		Route{
+			"View Merits",
+			"GET",
+			"/merits",
+			node.ViewMerits,
+		},
+		Route{
+			"View Transactions",
+			"GET",
+			"/transactions",
+			node.ViewTransactions,
+		},
+		Route{
+			"MinerBalance",
+			"GET",
+			"/balance",
+			node.MinerBalance,
+		},
+		Route{
+			"NodeInfo",
+			"GET",
+			"/node/info",
+			node.NodeInfo,
+		},
+		Route{
+			"ViewPeers",
+			"GET",
+			"/peers",
+			node.ViewPeers,
+		},
+		Route{
+			"Events",
+			"GET",
+			"/events",
+			node.Events,
+		},
+	}
 }
@@ -0,0 +1,372 @@
+package p3
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Types of the messages on a peer connection, every request is answered by a reply carrying its id
+const (
+	MsgHeartBeat   = "heartbeat"
+	MsgDownload    = "download"
+	MsgBlock       = "block"
+	MsgTransaction = "transaction"
+	MsgReply       = "reply"
+)
+
+// Limits of a peer connection. A served connection only reads requests, the largest being a heartbeat, a dialed
+// connection reads replies carrying at most the whole chain. A peer sending more than MAX_SERVED_REQUESTS requests
+// at once has to wait for the replies before its next request is read
+const (
+	MAX_REQUEST_SIZE    = 1 << 20
+	MAX_REPLY_SIZE      = 256 << 20
+	MAX_SERVED_REQUESTS = 16
+)
+
+var errSocketClosed = errors.New("peer connection closed")
+var errSocketTimeout = errors.New("peer did not reply in time")
+
+// SocketMessage is a request or a reply on a peer connection. Body holds a heartbeat or the binary encoding of
+// a block, transaction or chain. Status is the http status the request would have been answered with by the
+// http endpoints, Observed is the address the replying peer sees the requester at
+type SocketMessage struct {
+	Id       uint64 `json:"id"`
+	Type     string `json:"type"`
+	Height   int32  `json:"height,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+	Body     []byte `json:"body,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Observed string `json:"observed,omitempty"`
+}
+
+// WebSocketTransport keeps one long-lived websocket connection to every peer and multiplexes heartbeats, block and
+// transaction requests and chain downloads over it. A broken connection is dialed again on the next message
+type WebSocketTransport struct {
+	dialer  *websocket.Dialer
+	timeout time.Duration
+	conns   map[string]*socketConn
+	closed  bool
+	mux     sync.Mutex
+}
+
+func NewWebSocketTransport(timeout time.Duration) *WebSocketTransport {
+	return &WebSocketTransport{
+		dialer:  &websocket.Dialer{HandshakeTimeout: timeout},
+		timeout: timeout,
+		conns:   make(map[string]*socketConn),
+	}
+}
+
+func (t *WebSocketTransport) SendHeartBeat(addr string, hbdJSON []byte) (string, error) {
+	reply, err := t.request(addr, SocketMessage{Type: MsgHeartBeat, Body: hbdJSON}, t.timeout)
+	if err != nil {
+		return "", err
+	}
+	return reply.Observed, nil
+}
+
+func (t *WebSocketTransport) Download(addr string, hbdJSON []byte) ([]byte, string, error) {
+	reply, err := t.request(addr, SocketMessage{Type: MsgDownload, Body: hbdJSON}, time.Minute)
+	if err != nil {
+		return nil, "", err
+	}
+	if reply.Status != http.StatusOK {
+		return nil, "", errors.New("status " + http.StatusText(reply.Status))
+	}
+	return reply.Body, reply.Observed, nil
+}
+
+func (t *WebSocketTransport) FetchBlock(addr string, height int32, hash string) ([]byte, error) {
+	return t.fetch(addr, SocketMessage{Type: MsgBlock, Height: height, Hash: hash})
+}
+
+func (t *WebSocketTransport) FetchTransaction(addr string, hash string) ([]byte, error) {
+	return t.fetch(addr, SocketMessage{Type: MsgTransaction, Hash: hash})
+}
+
+// Close closes the connections to all peers, the transport cannot be used afterwards
+func (t *WebSocketTransport) Close() error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.closed = true
+	for addr, conn := range t.conns {
+		conn.close()
+		delete(t.conns, addr)
+	}
+	return nil
+}
+
+func (t *WebSocketTransport) fetch(addr string, msg SocketMessage) ([]byte, error) {
+	reply, err := t.request(addr, msg, t.timeout)
+	if err != nil {
+		return nil, err
+	}
+	if reply.Status != http.StatusOK {
+		return nil, errNotFound
+	}
+	return reply.Body, nil
+}
+
+// request sends msg to the peer and waits for the reply, the connection is dropped if the peer does not reply
+func (t *WebSocketTransport) request(addr string, msg SocketMessage, timeout time.Duration) (SocketMessage, error) {
+	conn, err := t.connect(addr)
+	if err != nil {
+		return SocketMessage{}, err
+	}
+	reply, err := conn.request(msg, timeout)
+	if err != nil {
+		conn.close()
+		t.mux.Lock()
+		if t.conns[addr] == conn {
+			delete(t.conns, addr)
+		}
+		t.mux.Unlock()
+	}
+	return reply, err
+}
+
+// connect returns the open connection to the peer or dials a new one
+func (t *WebSocketTransport) connect(addr string) (*socketConn, error) {
+	t.mux.Lock()
+	conn, ok := t.conns[addr]
+	closed := t.closed
+	t.mux.Unlock()
+	if closed {
+		return nil, errSocketClosed
+	}
+	if ok && conn.alive() {
+		return conn, nil
+	}
+
+	socketURL, err := peerSocketURL(addr)
+	if err != nil {
+		return nil, err
+	}
+	ws, _, err := t.dialer.Dial(socketURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	conn = newSocketConn(ws, nil)
+
+	//Another request may have dialed the peer in the meantime, only one connection is kept
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.closed {
+		conn.close()
+		return nil, errSocketClosed
+	}
+	if existing, ok := t.conns[addr]; ok && existing.alive() {
+		conn.close()
+		return existing, nil
+	}
+	t.conns[addr] = conn
+	return conn, nil
+}
+
+// peerSocketURL turns the http address of a peer into the url of its peer socket endpoint
+func peerSocketURL(addr string) (string, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	u.Path = "/peer/socket"
+	return u.String(), nil
+}
+
+// PeerSocket upgrades a request of a peer using the websocket transport and answers the messages of the peer
+// until it disconnects or this node stops. The hijacked connection is not tracked by the http server, so Stop
+// waits for the connection and the requests served on it instead
+func (node *Node) PeerSocket(w http.ResponseWriter, r *http.Request) {
+	if !node.enter() {
+		http.Error(w, "node is stopping", http.StatusServiceUnavailable)
+		return
+	}
+	defer node.wg.Done()
+	upgrader := websocket.Upgrader{}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		//The upgrader has answered with an error already
+		return
+	}
+	conn := newSocketConn(ws, func(msg SocketMessage) SocketMessage {
+		return node.serveSocketMessage(msg, r.RemoteAddr)
+	})
+	select {
+	case <-conn.done:
+	case <-node.ctx.Done():
+		conn.close()
+	}
+	<-conn.finished
+}
+
+// serveSocketMessage answers a request the way the http endpoint of the same purpose does
+func (node *Node) serveSocketMessage(msg SocketMessage, remoteAddr string) SocketMessage {
+	if node.ctx.Err() != nil {
+		return SocketMessage{Status: http.StatusServiceUnavailable}
+	}
+	switch msg.Type {
+	case MsgHeartBeat, MsgDownload:
+		hbd, status := node.acceptHeartBeat(msg.Body)
+		if status != http.StatusOK {
+			return SocketMessage{Status: status}
+		}
+		reply := SocketMessage{Status: http.StatusOK, Observed: observedAddr(remoteAddr, hbd.Addr)}
+		if msg.Type == MsgDownload {
+			reply.Body = node.upload(hbd)
+		} else {
+			node.receiveHeartBeat(hbd)
+		}
+		return reply
+	case MsgBlock:
+		if block, ok := node.sbc.GetBlock(msg.Height, msg.Hash); ok {
+			return SocketMessage{Status: http.StatusOK, Body: block.Encode()}
+		}
+		return SocketMessage{Status: http.StatusNoContent}
+	case MsgTransaction:
+		if t, ok := node.findTransaction(msg.Hash); ok {
+			return SocketMessage{Status: http.StatusOK, Body: t.Encode()}
+		}
+		return SocketMessage{Status: http.StatusNoContent}
+	}
+	return SocketMessage{Status: http.StatusBadRequest}
+}
+
+// socketConn multiplexes requests and replies over one websocket connection. Replies are matched to the waiting
+// request by id, incoming requests are answered by serve, a connection without serve only sends requests
+type socketConn struct {
+	ws       *websocket.Conn
+	serve    func(msg SocketMessage) SocketMessage
+	writeMux sync.Mutex
+	pending  map[uint64]chan SocketMessage
+	nextId   uint64
+	mux      sync.Mutex
+	served   chan struct{} // one slot per request being served
+	serving  sync.WaitGroup
+	done     chan struct{} // closed when the connection is closed
+	finished chan struct{} // closed when the read loop and the requests it served have returned
+	once     sync.Once
+}
+
+func newSocketConn(ws *websocket.Conn, serve func(msg SocketMessage) SocketMessage) *socketConn {
+	conn := &socketConn{
+		ws:       ws,
+		serve:    serve,
+		pending:  make(map[uint64]chan SocketMessage),
+		served:   make(chan struct{}, MAX_SERVED_REQUESTS),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	if serve != nil {
+		ws.SetReadLimit(MAX_REQUEST_SIZE)
+	} else {
+		ws.SetReadLimit(MAX_REPLY_SIZE)
+	}
+	go conn.readLoop()
+	return conn
+}
+
+func (conn *socketConn) readLoop() {
+	defer func() {
+		conn.close()
+		conn.serving.Wait()
+		close(conn.finished)
+	}()
+	for {
+		var msg SocketMessage
+		if err := conn.ws.ReadJSON(&msg); err != nil {
+			return
+		}
+		if msg.Type == MsgReply {
+			conn.mux.Lock()
+			reply, ok := conn.pending[msg.Id]
+			delete(conn.pending, msg.Id)
+			conn.mux.Unlock()
+			if ok {
+				reply <- msg
+			}
+			continue
+		}
+		if conn.serve != nil {
+			//Requests are served concurrently so that a chain download does not hold up the heartbeats
+			select {
+			case conn.served <- struct{}{}:
+			case <-conn.done:
+				return
+			}
+			conn.serving.Add(1)
+			go func(msg SocketMessage) {
+				defer func() {
+					<-conn.served
+					conn.serving.Done()
+				}()
+				reply := conn.serve(msg)
+				reply.Id = msg.Id
+				reply.Type = MsgReply
+				conn.write(reply)
+			}(msg)
+		}
+	}
+}
+
+func (conn *socketConn) request(msg SocketMessage, timeout time.Duration) (SocketMessage, error) {
+	reply := make(chan SocketMessage, 1)
+	conn.mux.Lock()
+	conn.nextId++
+	msg.Id = conn.nextId
+	conn.pending[msg.Id] = reply
+	conn.mux.Unlock()
+	defer func() {
+		conn.mux.Lock()
+		delete(conn.pending, msg.Id)
+		conn.mux.Unlock()
+	}()
+
+	if err := conn.write(msg); err != nil {
+		return SocketMessage{}, err
+	}
+	select {
+	case res := <-reply:
+		return res, nil
+	case <-conn.done:
+		return SocketMessage{}, errSocketClosed
+	case <-time.After(timeout):
+		return SocketMessage{}, errSocketTimeout
+	}
+}
+
+func (conn *socketConn) write(msg SocketMessage) error {
+	conn.writeMux.Lock()
+	defer conn.writeMux.Unlock()
+	conn.ws.SetWriteDeadline(time.Now().Add(time.Minute))
+	if err := conn.ws.WriteJSON(msg); err != nil {
+		conn.close()
+		return err
+	}
+	return nil
+}
+
+func (conn *socketConn) alive() bool {
+	select {
+	case <-conn.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (conn *socketConn) close() {
+	conn.once.Do(func() {
+		close(conn.done)
+		conn.ws.Close()
+	})
+}
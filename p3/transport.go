@@ -0,0 +1,142 @@
+package p3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// BINARY_CONTENT_TYPE is the content type of blocks, transactions and chains in canonical binary encoding,
+// peers ask for it with the Accept header while api clients get json
+const BINARY_CONTENT_TYPE = "application/octet-stream"
+
+// errNotFound means the peer answered but does not have the requested block or transaction
+var errNotFound = errors.New("not found")
+
+// Transport carries the messages of this node to its peers, peers are addressed by the http address they advertise.
+// Blocks, transactions and chains are transferred in canonical binary encoding.
+// An error other than errNotFound means the peer could not be reached
+type Transport interface {
+	// SendHeartBeat delivers a signed heartbeat, it returns the address the peer observed this node at, if any
+	SendHeartBeat(addr string, hbdJSON []byte) (string, error)
+	// Download sends a signed heartbeat and returns the entire blockchain of the peer and the observed address
+	Download(addr string, hbdJSON []byte) ([]byte, string, error)
+	// FetchBlock returns the encoding of a block of the peer
+	FetchBlock(addr string, height int32, hash string) ([]byte, error)
+	// FetchTransaction returns the encoding of a transaction of the peer
+	FetchTransaction(addr string, hash string) ([]byte, error)
+	// Close releases the connections to the peers
+	Close() error
+}
+
+func (node *Node) newTransport() (Transport, error) {
+	timeout := time.Duration(node.config.Network.PeerTimeout)
+	switch node.config.Network.Transport {
+	case "http":
+		return NewHTTPTransport(timeout, nil), nil
+	case "websocket":
+		return NewWebSocketTransport(timeout), nil
+	}
+	return nil, fmt.Errorf("unknown transport %v", node.config.Network.Transport)
+}
+
+// HTTPTransport sends every message as a separate request to the endpoints of the peer
+type HTTPTransport struct {
+	peerClient     *http.Client // used for all requests to peers so that dead peers time out
+	downloadClient *http.Client // used for downloading the entire blockchain, which takes longer than other requests
+}
+
+// NewHTTPTransport returns a transport whose requests time out after timeout, roundTripper replaces the default
+// http transport when not nil, e.g. by an in-process network simulator
+func NewHTTPTransport(timeout time.Duration, roundTripper http.RoundTripper) *HTTPTransport {
+	return &HTTPTransport{
+		peerClient:     &http.Client{Timeout: timeout, Transport: roundTripper},
+		downloadClient: &http.Client{Timeout: time.Minute, Transport: roundTripper},
+	}
+}
+
+func (t *HTTPTransport) SendHeartBeat(addr string, hbdJSON []byte) (string, error) {
+	res, err := t.peerClient.Post(addr+"/heartbeat/receive", "application/json", bytes.NewBuffer(hbdJSON))
+	if err != nil {
+		return "", err
+	}
+	res.Body.Close()
+	return res.Header.Get(OBSERVED_ADDR_HEADER), nil
+}
+
+func (t *HTTPTransport) Download(addr string, hbdJSON []byte) ([]byte, string, error) {
+	res, err := t.downloadClient.Post(addr+"/upload", "application/json", bytes.NewBuffer(hbdJSON))
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("status %v", res.Status)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, res.Header.Get(OBSERVED_ADDR_HEADER), nil
+}
+
+func (t *HTTPTransport) FetchBlock(addr string, height int32, hash string) ([]byte, error) {
+	return t.get(addr + "/block/" + strconv.Itoa(int(height)) + "/" + hash)
+}
+
+func (t *HTTPTransport) FetchTransaction(addr string, hash string) ([]byte, error) {
+	return t.get(addr + "/transaction/" + hash)
+}
+
+func (t *HTTPTransport) Close() error {
+	t.peerClient.CloseIdleConnections()
+	t.downloadClient.CloseIdleConnections()
+	return nil
+}
+
+func (t *HTTPTransport) get(url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", BINARY_CONTENT_TYPE)
+	res, err := t.peerClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errNotFound
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// acceptsBinary returns true if the request asks for the canonical binary encoding instead of json
+func acceptsBinary(r *http.Request) bool {
+	return r.Header.Get("Accept") == BINARY_CONTENT_TYPE
+}
+
+// readBody reads a request body of at most MAX_REQUEST_SIZE bytes. A body that is too large is answered with 413
+// and one that cannot be read, e.g. because the client went away, with 400
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MAX_REQUEST_SIZE))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return nil, false
+	}
+	return body, true
+}
+
+func writeBinary(w http.ResponseWriter, data []byte) {
+	w.Header().Set("Content-Type", BINARY_CONTENT_TYPE)
+	w.Write(data)
+}
@@ -0,0 +1,164 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"../harness"
+	"../p3"
+	"../transaction"
+)
+
+// PollInterval is how often the wait helpers check the nodes
+var PollInterval = 100 * time.Millisecond
+
+// Network is a set of nodes connected through a simulator, node 0 starts the chain and every other node uses it as seed
+type Network struct {
+	Sim    *Simulator
+	Nodes  []*p3.Node
+	Hosts  []string
+	cancel context.CancelFunc
+}
+
+// Config returns the configuration of simulated nodes. Unreachable peers are retried instead of evicted so that
+// the sides of a partition find each other again once it heals
+func Config() p3.Config {
+	config := harness.Config()
+	config.Network.MaxFailures = 50
+	return config
+}
+
+// Launch starts n nodes on the simulated network, configure, if not nil, can adjust the configuration of each node
+func (sim *Simulator) Launch(n int, configure func(i int, config *p3.Config)) (*Network, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	network := &Network{Sim: sim, cancel: cancel}
+	for i := 0; i < n; i++ {
+		host := fmt.Sprintf("node%d:7000", i)
+		config := Config()
+		config.API.Port = "7000"
+		config.Network.AdvertiseAddr = "http://" + host
+		if i > 0 {
+			config.Network.Seeds = []string{"http://" + network.Hosts[0]}
+		}
+		if configure != nil {
+			configure(i, &config)
+		}
+		if err := config.Validate(); err != nil {
+			network.Close()
+			return nil, fmt.Errorf("node %d: %v", i, err)
+		}
+		node, err := p3.NewNode(config)
+		if err != nil {
+			network.Close()
+			return nil, fmt.Errorf("node %d: %v", i, err)
+		}
+		node.SetTransport(p3.NewHTTPTransport(time.Duration(config.Network.PeerTimeout), sim.Transport(host)))
+		sim.Register(host, node.Handler())
+		network.Nodes = append(network.Nodes, node)
+		network.Hosts = append(network.Hosts, host)
+	}
+	for _, node := range network.Nodes {
+		node.Run(ctx)
+	}
+	return network, nil
+}
+
+// Close heals the network and stops all nodes
+func (network *Network) Close() {
+	network.Sim.Heal()
+	network.cancel()
+	for _, node := range network.Nodes {
+		node.Stop()
+	}
+}
+
+// Partition splits the nodes into groups of node indexes that cannot reach each other
+func (network *Network) Partition(groups ...[]int) {
+	hostGroups := make([][]string, 0, len(groups))
+	for _, group := range groups {
+		hosts := make([]string, 0, len(group))
+		for _, i := range group {
+			hosts = append(hosts, network.Hosts[i])
+		}
+		hostGroups = append(hostGroups, hosts)
+	}
+	network.Sim.Partition(hostGroups...)
+}
+
+// Heal reconnects all nodes
+func (network *Network) Heal() {
+	network.Sim.Heal()
+}
+
+// SubmitTx hands a transaction to node i as if it was posted to its /transaction endpoint
+func (network *Network) SubmitTx(i int, t tx.Transaction) error {
+	return network.Nodes[i].SubmitTransaction(t)
+}
+
+// WaitForBootstrap waits until every node has downloaded the chain, started mining and knows all other nodes
+func (network *Network) WaitForBootstrap(timeout time.Duration) error {
+	return waitFor(timeout, func() error {
+		for i, node := range network.Nodes {
+			info := node.Info()
+			if !info.Bootstrapped {
+				return fmt.Errorf("node %d has not bootstrapped", i)
+			}
+			if info.Peers < len(network.Nodes)-1 {
+				return fmt.Errorf("node %d knows %d peers, want %d", i, info.Peers, len(network.Nodes)-1)
+			}
+		}
+		return nil
+	})
+}
+
+// WaitForHeight waits until each of the given nodes, or all nodes if none is given, has at least height blocks
+func (network *Network) WaitForHeight(height int32, timeout time.Duration, nodes ...int) error {
+	if len(nodes) == 0 {
+		nodes = network.all()
+	}
+	return waitFor(timeout, func() error {
+		for _, i := range nodes {
+			if current := network.Nodes[i].Info().Height; current < height {
+				return fmt.Errorf("node %d has height %d, want %d", i, current, height)
+			}
+		}
+		return nil
+	})
+}
+
+// WaitForConvergence waits until all nodes hold the same blocks, i.e. their Show() hashes are equal
+func (network *Network) WaitForConvergence(timeout time.Duration) error {
+	return waitFor(timeout, func() error {
+		first := network.Nodes[0].Info().ChainHash
+		for i, node := range network.Nodes[1:] {
+			if hash := node.Info().ChainHash; hash != first {
+				return fmt.Errorf("node %d has chain %v, node 0 has %v", i+1, hash, first)
+			}
+		}
+		return nil
+	})
+}
+
+func (network *Network) all() []int {
+	nodes := make([]int, len(network.Nodes))
+	for i := range nodes {
+		nodes[i] = i
+	}
+	return nodes
+}
+
+// waitFor polls check until it returns nil, the last error is returned once timeout expires
+func waitFor(timeout time.Duration, check func() error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("after %v: %v", timeout, err)
+		}
+		time.Sleep(PollInterval)
+	}
+}
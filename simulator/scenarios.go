@@ -0,0 +1,115 @@
+package simulator
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"../harness"
+	"../models"
+)
+
+// ConvergenceTimeout bounds how long the nodes of a scenario may take to hold the same blocks
+var ConvergenceTimeout = time.Minute
+
+// Scenario sets up network conditions and returns an error if the nodes do not converge
+type Scenario struct {
+	Name string
+	Run  func(sim *Simulator) error
+}
+
+// Scenarios are the adversarial network conditions every change to gossip or fork resolution has to survive
+var Scenarios = []Scenario{
+	{"partition-heal", PartitionHeal},
+	{"isolated-node-catches-up", IsolatedNodeCatchesUp},
+	{"lossy-slow-network", LossySlowNetwork},
+}
+
+// PartitionHeal splits four nodes in two halves that mine competing forks, after healing every node has to hold both forks
+func PartitionHeal(sim *Simulator) error {
+	network, err := sim.Launch(4, nil)
+	if err != nil {
+		return err
+	}
+	defer network.Close()
+	if err := network.WaitForBootstrap(ConvergenceTimeout); err != nil {
+		return err
+	}
+
+	network.Partition([]int{0, 1}, []int{2, 3})
+	if err := submit(network, 0, 2); err != nil {
+		return err
+	}
+	if err := network.WaitForHeight(1, ConvergenceTimeout); err != nil {
+		return err
+	}
+	network.Heal()
+	return network.WaitForConvergence(ConvergenceTimeout)
+}
+
+// IsolatedNodeCatchesUp cuts one node off while the others extend the chain, once reconnected it has to fetch
+// the missing blocks through AskForBlock
+func IsolatedNodeCatchesUp(sim *Simulator) error {
+	network, err := sim.Launch(4, nil)
+	if err != nil {
+		return err
+	}
+	defer network.Close()
+	if err := network.WaitForBootstrap(ConvergenceTimeout); err != nil {
+		return err
+	}
+
+	network.Partition([]int{0, 1, 2}, []int{3})
+	for height := int32(1); height <= 3; height++ {
+		if err := submit(network, int(height-1)); err != nil {
+			return err
+		}
+		if err := network.WaitForHeight(height, ConvergenceTimeout, 0, 1, 2); err != nil {
+			return err
+		}
+	}
+	network.Heal()
+	return network.WaitForConvergence(ConvergenceTimeout)
+}
+
+// LossySlowNetwork submits transactions to every node while a fifth of the messages is lost and the rest is delayed
+func LossySlowNetwork(sim *Simulator) error {
+	sim.SetLatency(20*time.Millisecond, 80*time.Millisecond)
+	sim.SetDropRate(0.2)
+	network, err := sim.Launch(5, nil)
+	if err != nil {
+		return err
+	}
+	defer network.Close()
+	if err := network.WaitForBootstrap(ConvergenceTimeout); err != nil {
+		return err
+	}
+
+	if err := submit(network, 0, 1, 2, 3, 4); err != nil {
+		return err
+	}
+	if err := network.WaitForHeight(1, ConvergenceTimeout); err != nil {
+		return err
+	}
+	return network.WaitForConvergence(ConvergenceTimeout)
+}
+
+// submit hands a fresh transaction to each of the given nodes
+func submit(network *Network, nodes ...int) error {
+	for _, i := range nodes {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return err
+		}
+		t, err := harness.NewApplication(key, models.Merit{Experience: []string{fmt.Sprintf("node %d", i)}}, 0.1)
+		if err != nil {
+			return err
+		}
+		if err := network.SubmitTx(i, t); err != nil {
+			return fmt.Errorf("node %d refused transaction: %v", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package simulator
+
+import (
+	"testing"
+	"time"
+)
+
+// SCENARIO_DEADLINE bounds a whole scenario, a scenario that hangs fails instead of blocking the test binary
+const SCENARIO_DEADLINE = 3 * time.Minute
+
+func TestScenarios(t *testing.T) {
+	seed := time.Now().UnixNano()
+	t.Logf("seed %d", seed)
+	for _, scenario := range Scenarios {
+		scenario := scenario
+		t.Run(scenario.Name, func(t *testing.T) {
+			if testing.Short() {
+				t.Skip("scenarios start clusters of nodes")
+			}
+			done := make(chan error, 1)
+			go func() {
+				done <- scenario.Run(NewSimulator(seed))
+			}()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("seed %d: %v", seed, err)
+				}
+			case <-time.After(SCENARIO_DEADLINE):
+				t.Fatalf("seed %d: did not finish within %v", seed, SCENARIO_DEADLINE)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Package simulator runs nodes on an in-process network where the links between them can be delayed, made lossy,
+// partitioned and healed, to reproduce adversarial network conditions in fork resolution scenarios.
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+var errUnreachable = errors.New("simulator: host unreachable")
+var errDropped = errors.New("simulator: message dropped")
+
+// Simulator routes the requests between the registered hosts, each request suffers the latency and loss of the network
+// and fails while its hosts are partitioned from each other
+type Simulator struct {
+	handlers map[string]http.Handler
+	latency  time.Duration
+	jitter   time.Duration
+	dropRate float64
+	cut      map[[2]string]bool
+	random   *rand.Rand
+	mux      sync.Mutex
+}
+
+func NewSimulator(seed int64) *Simulator {
+	return &Simulator{
+		handlers: make(map[string]http.Handler),
+		cut:      make(map[[2]string]bool),
+		random:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Register makes handler reachable at host, host is the host:port part of the node address
+func (sim *Simulator) Register(host string, handler http.Handler) {
+	sim.mux.Lock()
+	defer sim.mux.Unlock()
+	sim.handlers[host] = handler
+}
+
+// SetLatency delays every request by latency plus a random part of jitter
+func (sim *Simulator) SetLatency(latency time.Duration, jitter time.Duration) {
+	sim.mux.Lock()
+	defer sim.mux.Unlock()
+	sim.latency = latency
+	sim.jitter = jitter
+}
+
+// SetDropRate makes requests fail with probability rate
+func (sim *Simulator) SetDropRate(rate float64) {
+	sim.mux.Lock()
+	defer sim.mux.Unlock()
+	sim.dropRate = rate
+}
+
+// Partition cuts every link between hosts of different groups, hosts that are not in any group stay connected to all
+func (sim *Simulator) Partition(groups ...[]string) {
+	sim.mux.Lock()
+	defer sim.mux.Unlock()
+	for i, group := range groups {
+		for _, other := range groups[i+1:] {
+			for _, a := range group {
+				for _, b := range other {
+					sim.cut[[2]string{a, b}] = true
+					sim.cut[[2]string{b, a}] = true
+				}
+			}
+		}
+	}
+}
+
+// Heal reconnects all hosts
+func (sim *Simulator) Heal() {
+	sim.mux.Lock()
+	defer sim.mux.Unlock()
+	sim.cut = make(map[[2]string]bool)
+}
+
+// Transport returns the round tripper host uses to reach the other hosts
+func (sim *Simulator) Transport(host string) http.RoundTripper {
+	return &link{sim: sim, from: host}
+}
+
+// route decides the fate of a request, it returns the handler and the delay or the reason the request fails
+func (sim *Simulator) route(from string, to string) (http.Handler, time.Duration, error) {
+	sim.mux.Lock()
+	defer sim.mux.Unlock()
+	handler, ok := sim.handlers[to]
+	if !ok || sim.cut[[2]string{from, to}] {
+		return nil, 0, errUnreachable
+	}
+	if sim.random.Float64() < sim.dropRate {
+		return nil, 0, errDropped
+	}
+	delay := sim.latency
+	if sim.jitter > 0 {
+		delay += time.Duration(sim.random.Int63n(int64(sim.jitter)))
+	}
+	return handler, delay, nil
+}
+
+// link carries the requests of one host
+type link struct {
+	sim  *Simulator
+	from string
+}
+
+func (l *link) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	handler, delay, err := l.sim.route(l.from, req.URL.Host)
+	if err != nil {
+		return nil, fmt.Errorf("%v %v: %v", req.Method, req.URL, err)
+	}
+	select {
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case <-time.After(delay):
+	}
+
+	inbound := req.Clone(req.Context())
+	inbound.RemoteAddr = l.from
+	inbound.RequestURI = req.URL.RequestURI()
+	if inbound.Body == nil {
+		inbound.Body = http.NoBody
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, inbound)
+	return recorder.Result(), nil
+}
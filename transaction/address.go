@@ -0,0 +1,78 @@
+package tx
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// ADDRESS_PREFIX starts every account address so that addresses are not mistaken for hashes or keys
+const ADDRESS_PREFIX = "jm"
+
+// An address is ADDRESS_PREFIX followed by the hex of the first ADDRESS_HASH_SIZE bytes of the sha256 of the
+// compressed public key and ADDRESS_CHECKSUM_SIZE bytes of checksum over them
+const (
+	ADDRESS_HASH_SIZE     = 20
+	ADDRESS_CHECKSUM_SIZE = 4
+)
+
+var errAddressChecksum = errors.New("address checksum mismatch")
+var errAddressCase = errors.New("address must be lowercase hex")
+
+// Address returns the account address of a public key, blocks credit fees to it and queries look accounts up by it
+func Address(publicKey *ecdsa.PublicKey) string {
+	return addressOf(EncodeECDSAPublicKey(publicKey))
+}
+
+// AddressFromPublicKey returns the account address of a public key encoded by EncodeECDSAPublicKey
+func AddressFromPublicKey(encodedPub string) (string, error) {
+	if _, err := DecodeECDSAPublicKey(encodedPub); err != nil {
+		return "", err
+	}
+	return addressOf(encodedPub), nil
+}
+
+// FromAddress returns the account address of the sender, or "" if the sender key is malformed
+func (tx *Transaction) FromAddress() string {
+	addr, _ := AddressFromPublicKey(tx.From)
+	return addr
+}
+
+// ValidateAddress returns an error if addr is not a well-formed address or its checksum does not match. Addresses
+// are compared as strings, so only the lowercase form produced by Address is well-formed
+func ValidateAddress(addr string) error {
+	if !strings.HasPrefix(addr, ADDRESS_PREFIX) {
+		return errors.New("address does not start with " + ADDRESS_PREFIX)
+	}
+	if addr != strings.ToLower(addr) {
+		return errAddressCase
+	}
+	raw, err := hex.DecodeString(addr[len(ADDRESS_PREFIX):])
+	if err != nil {
+		return err
+	}
+	if len(raw) != ADDRESS_HASH_SIZE+ADDRESS_CHECKSUM_SIZE {
+		return errors.New("address has the wrong length")
+	}
+	if !bytes.Equal(addressChecksum(raw[:ADDRESS_HASH_SIZE]), raw[ADDRESS_HASH_SIZE:]) {
+		return errAddressChecksum
+	}
+	return nil
+}
+
+func addressOf(encodedPub string) string {
+	point, _ := hex.DecodeString(encodedPub)
+	sum := sha256.Sum256(point)
+	hash := append([]byte(nil), sum[:ADDRESS_HASH_SIZE]...)
+	return ADDRESS_PREFIX + hex.EncodeToString(append(hash, addressChecksum(hash)...))
+}
+
+// addressChecksum catches mistyped addresses before a query or a payout silently goes to the wrong account
+func addressChecksum(hash []byte) []byte {
+	first := sha256.Sum256(hash)
+	second := sha256.Sum256(first[:])
+	return second[:ADDRESS_CHECKSUM_SIZE]
+}
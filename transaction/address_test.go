@@ -0,0 +1,78 @@
+package tx
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateAddress(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := Address(&key.PublicKey)
+	hash := len(ADDRESS_PREFIX)
+	tests := []struct {
+		name  string
+		addr  string
+		valid bool
+		err   error
+	}{
+		{"address of a key", addr, true, nil},
+		{"mistyped checksum", mistype(addr, len(addr)-1), false, errAddressChecksum},
+		{"mistyped hash", mistype(addr, hash), false, errAddressChecksum},
+		{"uppercase", ADDRESS_PREFIX + strings.ToUpper(addr[hash:]), false, errAddressCase},
+		{"uppercase prefix", strings.ToUpper(ADDRESS_PREFIX) + addr[hash:], false, nil},
+		{"public key", EncodeECDSAPublicKey(&key.PublicKey), false, nil},
+		{"missing checksum", addr[:len(addr)-2*ADDRESS_CHECKSUM_SIZE], false, nil},
+		{"odd length", addr[:len(addr)-1], false, nil},
+		{"not hex", addr[:len(addr)-1] + "g", false, nil},
+		{"empty", "", false, nil},
+	}
+	for _, test := range tests {
+		err := ValidateAddress(test.addr)
+		if (err == nil) != test.valid {
+			t.Errorf("%v: ValidateAddress(%q) = %v, want valid %v", test.name, test.addr, err, test.valid)
+		}
+		if err != nil && test.err != nil && !errors.Is(err, test.err) {
+			t.Errorf("%v: error %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+// mistype replaces the hex digit at i by another one
+func mistype(addr string, i int) string {
+	digit := "0"
+	if addr[i] == '0' {
+		digit = "1"
+	}
+	return addr[:i] + digit + addr[i+1:]
+}
+
+func TestAddressFromPublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, err := AddressFromPublicKey(EncodeECDSAPublicKey(&key.PublicKey))
+	if err != nil || addr != Address(&key.PublicKey) {
+		t.Fatalf("AddressFromPublicKey = %v, %v, want %v", addr, err, Address(&key.PublicKey))
+	}
+	tx := Transaction{From: EncodeECDSAPublicKey(&key.PublicKey)}
+	if tx.FromAddress() != addr {
+		t.Fatalf("FromAddress = %v, want %v", tx.FromAddress(), addr)
+	}
+
+	for _, encoded := range []string{"", "zz", "02", "05" + EncodeECDSAPublicKey(&key.PublicKey)[2:]} {
+		if _, err := AddressFromPublicKey(encoded); err == nil {
+			t.Errorf("AddressFromPublicKey(%q) accepted a malformed key", encoded)
+		}
+		if addr := (&Transaction{From: encoded}).FromAddress(); addr != "" {
+			t.Errorf("FromAddress of the malformed key %q = %v", encoded, addr)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package tx
+
+import (
+	"../codec"
+	"../models"
+)
+
+// unsignedBytes returns the canonical encoding of the fields covered by the hash and the signature
+func (tx *Transaction) unsignedBytes() []byte {
+	w := codec.NewWriter(codec.KIND_UNSIGNED_TRANSACTION)
+	tx.writeUnsigned(w)
+	return w.Encoded()
+}
+
+func (tx *Transaction) writeUnsigned(w *codec.Writer) {
+	w.Int32(tx.Version)
+	w.String(tx.From)
+	w.String(tx.To)
+	w.String(tx.TXType)
+	w.Float32(tx.TXFee)
+	w.Int64(tx.Timestamp)
+	w.String(tx.Payload)
+}
+
+// Encode returns the canonical binary encoding of the transaction, used for storage and transfer between nodes
+func (tx *Transaction) Encode() []byte {
+	w := codec.NewWriter(codec.KIND_TRANSACTION)
+	tx.writeUnsigned(w)
+	w.String(tx.Hash)
+	w.BigInt(tx.Signature.R)
+	w.BigInt(tx.Signature.S)
+	return w.Encoded()
+}
+
+// Decode reads a transaction written by Encode
+func (tx *Transaction) Decode(data []byte) error {
+	r := codec.NewReader(data, codec.KIND_TRANSACTION)
+	t := Transaction{
+		Version:   r.Int32(),
+		From:      r.String(),
+		To:        r.String(),
+		TXType:    r.String(),
+		TXFee:     r.Float32(),
+		Timestamp: r.Int64(),
+		Payload:   r.String(),
+		Hash:      r.String(),
+		Signature: models.ECDSASignature{R: r.BigInt(), S: r.BigInt()},
+	}
+	if err := r.Close(); err != nil {
+		return err
+	}
+	*tx = t
+	return nil
+}
+
+// EncodeTransactions returns the canonical encoding of a list of transactions
+func EncodeTransactions(txs []Transaction) []byte {
+	w := codec.NewWriter(codec.KIND_TRANSACTIONS)
+	w.Len(len(txs))
+	for _, t := range txs {
+		w.Bytes(t.Encode())
+	}
+	return w.Encoded()
+}
+
+// DecodeTransactions reads a list written by EncodeTransactions
+func DecodeTransactions(data []byte) ([]Transaction, error) {
+	r := codec.NewReader(data, codec.KIND_TRANSACTIONS)
+	n := r.Len()
+	txs := make([]Transaction, 0, n)
+	for i := 0; i < n && r.Err() == nil; i++ {
+		t := new(Transaction)
+		if err := t.Decode(r.Bytes()); err != nil {
+			return nil, err
+		}
+		txs = append(txs, *t)
+	}
+	if err := r.Close(); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
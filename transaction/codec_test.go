@@ -0,0 +1,134 @@
+package tx
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+
+	"../codec"
+	"../models"
+)
+
+func testTransactions() []Transaction {
+	return []Transaction{
+		{},
+		{
+			Version:   VERSION_1,
+			From:      "02b4632d08485ff1df2db55b9dafd23347d1c47a457072a1e87be26896549a8737",
+			To:        "",
+			TXType:    TYPE_CONFIRMATION,
+			TXFee:     0.1,
+			Timestamp: 1600000000000,
+			Payload:   "00ff",
+			Hash:      "5f6d",
+			Signature: models.ECDSASignature{R: big.NewInt(1), S: new(big.Int).Lsh(big.NewInt(1), 255)},
+		},
+		{Version: VERSION_1, TXType: TYPE_APPLICATION, TXFee: float32(math.Copysign(0, -1)), Payload: "{\"merit\":\"é\"}"},
+	}
+}
+
+func TestTransactionRoundTrip(t *testing.T) {
+	for i, original := range testTransactions() {
+		encoded := original.Encode()
+		if !bytes.Equal(encoded, original.Encode()) {
+			t.Errorf("transaction %d encodes differently every time", i)
+		}
+		var decoded Transaction
+		if err := decoded.Decode(encoded); err != nil {
+			t.Errorf("transaction %d: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(decoded.Encode(), encoded) || decoded.GenHash() != original.GenHash() {
+			t.Errorf("transaction %d changed in a round trip: %+v, want %+v", i, decoded, original)
+		}
+		if math.Float32bits(decoded.TXFee) != math.Float32bits(original.TXFee) {
+			t.Errorf("transaction %d: fee %v, want %v", i, decoded.TXFee, original.TXFee)
+		}
+	}
+}
+
+func TestTransactionHashIsPinned(t *testing.T) {
+	//The hash covers the canonical encoding of the unsigned fields, changing it invalidates all signed transactions
+	tx := testTransactions()[1]
+	want := "0201" + "00000001" +
+		"42" + hex.EncodeToString([]byte(tx.From)) +
+		"00" +
+		"0c" + hex.EncodeToString([]byte(TYPE_CONFIRMATION)) +
+		"3dcccccd" +
+		"00000174876e8000" +
+		"04" + hex.EncodeToString([]byte("00ff"))
+	if got := hex.EncodeToString(tx.unsignedBytes()); got != want {
+		t.Fatalf("unsigned encoding %v, want %v", got, want)
+	}
+	//The hash and the signature are not covered
+	signed := tx
+	signed.Hash, signed.Signature = "", models.ECDSASignature{}
+	if signed.GenHash() != tx.GenHash() {
+		t.Fatal("the hash depends on the hash or the signature")
+	}
+	fee := tx
+	fee.TXFee = math.Nextafter32(tx.TXFee, 1)
+	if fee.GenHash() == tx.GenHash() {
+		t.Fatal("the hash does not cover every bit of the fee")
+	}
+}
+
+func TestTransactionDecodeRefusesMalformedInput(t *testing.T) {
+	tx := testTransactions()[1]
+	valid := tx.Encode()
+	withVersion := append([]byte(nil), valid...)
+	withVersion[1] = codec.VERSION + 1
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, codec.ErrTruncated},
+		{"truncated", valid[:len(valid)-1], codec.ErrTruncated},
+		{"trailing bytes", append(append([]byte(nil), valid...), 0), codec.ErrTrailing},
+		{"unsigned encoding", tx.unsignedBytes(), codec.ErrKind},
+		{"list of transactions", EncodeTransactions([]Transaction{tx}), codec.ErrKind},
+		{"future version", withVersion, codec.ErrVersion},
+	}
+	for _, test := range tests {
+		decoded := Transaction{Hash: "untouched"}
+		if err := decoded.Decode(test.data); !errors.Is(err, test.want) {
+			t.Errorf("%v: error %v, want %v", test.name, err, test.want)
+		}
+		if decoded.Hash != "untouched" {
+			t.Errorf("%v: a failed decode changed the transaction", test.name)
+		}
+	}
+	for n := 0; n < len(valid); n++ {
+		if err := new(Transaction).Decode(valid[:n]); err == nil {
+			t.Errorf("prefix of %d bytes decoded", n)
+		}
+	}
+}
+
+func TestTransactionsRoundTrip(t *testing.T) {
+	tests := [][]Transaction{{}, testTransactions()}
+	for _, txs := range tests {
+		encoded := EncodeTransactions(txs)
+		decoded, err := DecodeTransactions(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded) != len(txs) || !bytes.Equal(EncodeTransactions(decoded), encoded) {
+			t.Fatalf("decoded %d transactions, want %d", len(decoded), len(txs))
+		}
+	}
+
+	encoded := EncodeTransactions(testTransactions())
+	for n := 0; n < len(encoded); n++ {
+		if _, err := DecodeTransactions(encoded[:n]); err == nil {
+			t.Errorf("prefix of %d bytes decoded", n)
+		}
+	}
+	if _, err := DecodeTransactions(append(encoded, 0)); !errors.Is(err, codec.ErrTrailing) {
+		t.Errorf("trailing bytes: error %v", err)
+	}
+}
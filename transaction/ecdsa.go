@@ -2,50 +2,97 @@ package tx
 
 import (
 	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
+	"errors"
+	"fmt"
 )
 
 /*
-	Functions for serializing and deserializing ecdsa Public key to String
-	this is a modified version from the original author mikijov:
-	https://stackoverflow.com/questions/21322182/how-to-store-ecdsa-private-key-in-go
+	Functions for serializing and deserializing public keys to String.
+	An ecdsa public key is the hex of its compressed P-256 point, which is the address of an account,
+	an rsa public key is the base64 of its PKCS #1 encoding
 */
 func EncodeECDSAPublicKey(publicKey *ecdsa.PublicKey) string {
-	x509EncodedPub, _ := x509.MarshalPKIXPublicKey(publicKey)
-	pemEncodedPub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: x509EncodedPub})
+	return hex.EncodeToString(elliptic.MarshalCompressed(elliptic.P256(), publicKey.X, publicKey.Y))
+}
 
-	pubkeystr := string(pemEncodedPub)
-	return pubkeystr[27 : len(pubkeystr)-26]
+func DecodeECDSAPublicKey(encodedPub string) (*ecdsa.PublicKey, error) {
+	point, err := hex.DecodeString(encodedPub)
+	if err != nil {
+		return nil, fmt.Errorf("malformed public key: %v", err)
+	}
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), point)
+	if x == nil {
+		return nil, errors.New("public key is not a compressed P-256 point")
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
 }
 
-func DecodeECDSAPublicKey(pemEncodedPub string) *ecdsa.PublicKey {
-	pemEncodedPub = "-----BEGIN PUBLIC KEY-----\n" + pemEncodedPub + "\n-----END PUBLIC KEY-----\n"
-	blockPub, _ := pem.Decode([]byte(pemEncodedPub))
-	x509EncodedPub := blockPub.Bytes
-	genericPublicKey, _ := x509.ParsePKIXPublicKey(x509EncodedPub)
-	publicKey := genericPublicKey.(*ecdsa.PublicKey)
+func EncodeRSAPublicKey(publicKey *rsa.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PublicKey(publicKey))
+}
 
-	return publicKey
+func DecodeRSAPublicKey(encodedPub string) (*rsa.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(encodedPub)
+	if err != nil {
+		return nil, fmt.Errorf("malformed rsa public key: %v", err)
+	}
+	return x509.ParsePKCS1PublicKey(der)
 }
 
-func EncodeRSAPublicKey(publicKey *rsa.PublicKey) string {
-	x509EncodedPub := x509.MarshalPKCS1PublicKey(publicKey)
-	pemEncodedPub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: x509EncodedPub})
+/*
+	Functions for serializing and deserializing private keys to PEM, this is the format the node reads its miner key
+	from and the keystore imports and exports. Keys written by older clients are labelled PRIVATE KEY and still accepted
+*/
+func EncodeECDSAPrivateKey(privateKey *ecdsa.PrivateKey) ([]byte, error) {
+	x509Encoded, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: x509Encoded}), nil
+}
 
-	pubkeystr := string(pemEncodedPub)
-	return pubkeystr[27 : len(pubkeystr)-26]
+func DecodeECDSAPrivateKey(pemEncoded []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemEncoded)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
 }
 
-func DecodeRSAPublicKey(pemEncodedPub string) *rsa.PublicKey {
-	pemEncodedPub = "-----BEGIN PUBLIC KEY-----\n" + pemEncodedPub + "\n-----END PUBLIC KEY-----\n"
-	blockPub, _ := pem.Decode([]byte(pemEncodedPub))
-	x509EncodedPub := blockPub.Bytes
+func EncodeRSAPrivateKey(privateKey *rsa.PrivateKey) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+}
 
-	rsapublicKey, err := x509.ParsePKCS1PublicKey(x509EncodedPub)
+func DecodeRSAPrivateKey(pemEncoded []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemEncoded)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// DecodeECDSAPublicKeyPEM reads a public key from a full PEM file, a private key file is accepted as well
+func DecodeECDSAPublicKeyPEM(pemEncoded []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(pemEncoded)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+	if privateKey, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return &privateKey.PublicKey, nil
+	}
+	genericPublicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	publicKey, ok := genericPublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not an ecdsa public key")
 	}
-	return rsapublicKey
+	return publicKey, nil
 }
@@ -0,0 +1,189 @@
+package tx
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"../models"
+)
+
+// Transaction types, each type carries the payload registered for it
+const (
+	TYPE_APPLICATION  = "application"
+	TYPE_ACCEPTANCE   = "acceptance"
+	TYPE_CONFIRMATION = "confirmation"
+)
+
+// MIN_RSA_BITS is the smallest employer key accepted in an acceptance
+const MIN_RSA_BITS = 2048
+
+var errUnknownType = errors.New("unknown transaction type")
+
+// Payload is the typed content of a transaction, it is stored as a string in Transaction.Payload
+type Payload interface {
+	// Type returns the transaction type carrying this payload
+	Type() string
+	// Encode returns the string stored in the transaction
+	Encode() (string, error)
+	// Decode reads the string stored in a transaction
+	Decode(payload string) error
+	// Validate checks the decoded content
+	Validate() error
+}
+
+// payloadTypes maps each transaction type to a constructor of its payload
+var payloadTypes = map[string]func() Payload{
+	TYPE_APPLICATION:  func() Payload { return new(ApplicationPayload) },
+	TYPE_ACCEPTANCE:   func() Payload { return new(AcceptancePayload) },
+	TYPE_CONFIRMATION: func() Payload { return new(ConfirmationPayload) },
+}
+
+// RegisterPayload adds a transaction type, newPayload returns an empty payload of the type
+func RegisterPayload(txType string, newPayload func() Payload) {
+	payloadTypes[txType] = newPayload
+}
+
+// NewPayload returns an empty payload for the transaction type
+func NewPayload(txType string) (Payload, error) {
+	newPayload, ok := payloadTypes[txType]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", errUnknownType, txType)
+	}
+	return newPayload(), nil
+}
+
+// DecodePayload decodes and validates the payload by the type of the transaction
+func (tx *Transaction) DecodePayload() (Payload, error) {
+	payload, err := NewPayload(tx.TXType)
+	if err != nil {
+		return nil, err
+	}
+	if err := payload.Decode(tx.Payload); err != nil {
+		return nil, fmt.Errorf("malformed %v payload: %v", tx.TXType, err)
+	}
+	if err := payload.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %v payload: %v", tx.TXType, err)
+	}
+	return payload, nil
+}
+
+// SetPayload validates the payload and stores it together with its type, the transaction has to be hashed afterwards
+func (tx *Transaction) SetPayload(payload Payload) error {
+	if err := payload.Validate(); err != nil {
+		return err
+	}
+	encoded, err := payload.Encode()
+	if err != nil {
+		return err
+	}
+	tx.TXType = payload.Type()
+	tx.Payload = encoded
+	return nil
+}
+
+// ApplicationPayload publishes the merit of an applicant, signed together with the identity that is kept private
+type ApplicationPayload struct {
+	models.SignedMerit
+}
+
+func (p *ApplicationPayload) Type() string {
+	return TYPE_APPLICATION
+}
+
+func (p *ApplicationPayload) Encode() (string, error) {
+	bytes, err := json.Marshal(p.SignedMerit)
+	return string(bytes), err
+}
+
+func (p *ApplicationPayload) Decode(payload string) error {
+	decoder := json.NewDecoder(bytes.NewBufferString(payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&p.SignedMerit); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("trailing data after merit")
+	}
+	return nil
+}
+
+func (p *ApplicationPayload) Validate() error {
+	if hash, err := hex.DecodeString(p.Hash); err != nil || len(hash) != 32 {
+		return errors.New("merit hash is not a sha256 hex digest")
+	}
+	if p.Signature.R == nil || p.Signature.S == nil || p.Signature.R.Sign() <= 0 || p.Signature.S.Sign() <= 0 {
+		return errors.New("merit is not signed")
+	}
+	if p.Timestamp <= 0 {
+		return errors.New("merit has no timestamp")
+	}
+	return nil
+}
+
+// AcceptancePayload carries the key of the employer accepting a merit, the applicant encrypts the identity with it
+type AcceptancePayload struct {
+	EmployerKey *rsa.PublicKey
+}
+
+func (p *AcceptancePayload) Type() string {
+	return TYPE_ACCEPTANCE
+}
+
+func (p *AcceptancePayload) Encode() (string, error) {
+	if p.EmployerKey == nil {
+		return "", errors.New("no employer key")
+	}
+	return EncodeRSAPublicKey(p.EmployerKey), nil
+}
+
+func (p *AcceptancePayload) Decode(payload string) error {
+	key, err := DecodeRSAPublicKey(payload)
+	if err != nil {
+		return err
+	}
+	p.EmployerKey = key
+	return nil
+}
+
+func (p *AcceptancePayload) Validate() error {
+	if p.EmployerKey == nil {
+		return errors.New("no employer key")
+	}
+	if p.EmployerKey.N.BitLen() < MIN_RSA_BITS {
+		return fmt.Errorf("employer key has %d bits, at least %d are required", p.EmployerKey.N.BitLen(), MIN_RSA_BITS)
+	}
+	return nil
+}
+
+// ConfirmationPayload carries the identity of the applicant encrypted with the key of the accepting employer
+type ConfirmationPayload struct {
+	Ciphertext []byte
+}
+
+func (p *ConfirmationPayload) Type() string {
+	return TYPE_CONFIRMATION
+}
+
+func (p *ConfirmationPayload) Encode() (string, error) {
+	return hex.EncodeToString(p.Ciphertext), nil
+}
+
+func (p *ConfirmationPayload) Decode(payload string) error {
+	ciphertext, err := hex.DecodeString(payload)
+	if err != nil {
+		return err
+	}
+	p.Ciphertext = ciphertext
+	return nil
+}
+
+func (p *ConfirmationPayload) Validate() error {
+	if len(p.Ciphertext) == 0 {
+		return errors.New("no encrypted identity")
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package tx
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"../models"
+)
+
+// testPayload is registered under a type of its own to check that the registry is open to new types
+type testPayload struct {
+	Value string
+}
+
+func (p *testPayload) Type() string {
+	return "test"
+}
+
+func (p *testPayload) Encode() (string, error) {
+	return p.Value, nil
+}
+
+func (p *testPayload) Decode(payload string) error {
+	p.Value = payload
+	return nil
+}
+
+func (p *testPayload) Validate() error {
+	if p.Value == "" {
+		return errors.New("empty")
+	}
+	return nil
+}
+
+func TestPayloadRegistry(t *testing.T) {
+	for _, txType := range []string{TYPE_APPLICATION, TYPE_ACCEPTANCE, TYPE_CONFIRMATION} {
+		payload, err := NewPayload(txType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if payload.Type() != txType {
+			t.Errorf("NewPayload(%q) returned a payload of type %q", txType, payload.Type())
+		}
+	}
+	if _, err := NewPayload("test"); !errors.Is(err, errUnknownType) {
+		t.Fatalf("unregistered type: error %v, want %v", err, errUnknownType)
+	}
+
+	RegisterPayload("test", func() Payload { return new(testPayload) })
+	defer delete(payloadTypes, "test")
+	var tx Transaction
+	if err := tx.SetPayload(&testPayload{Value: "value"}); err != nil {
+		t.Fatal(err)
+	}
+	if tx.TXType != "test" || tx.Payload != "value" {
+		t.Fatalf("SetPayload stored type %q and payload %q", tx.TXType, tx.Payload)
+	}
+	payload, err := tx.DecodePayload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.(*testPayload).Value != "value" {
+		t.Fatalf("decoded %+v", payload)
+	}
+	if err := tx.SetPayload(&testPayload{}); err == nil {
+		t.Fatal("SetPayload stored an invalid payload")
+	}
+}
+
+func TestPayloads(t *testing.T) {
+	employer, err := rsa.GenerateKey(rand.Reader, MIN_RSA_BITS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merit := models.SignedMerit{
+		Merit:     models.Merit{Experience: []string{"tests"}},
+		Hash:      strings.Repeat("ab", 32),
+		Timestamp: 1600000000000,
+		Signature: models.ECDSASignature{R: big.NewInt(1), S: big.NewInt(2)},
+	}
+	unsigned := merit
+	unsigned.Signature = models.ECDSASignature{}
+	shortHash := merit
+	shortHash.Hash = "ab"
+
+	tests := []struct {
+		name    string
+		payload Payload
+		valid   bool
+	}{
+		{"application", &ApplicationPayload{merit}, true},
+		{"unsigned application", &ApplicationPayload{unsigned}, false},
+		{"application with a short hash", &ApplicationPayload{shortHash}, false},
+		{"acceptance", &AcceptancePayload{&employer.PublicKey}, true},
+		{"acceptance with a small key", &AcceptancePayload{&small.PublicKey}, false},
+		{"acceptance without key", &AcceptancePayload{}, false},
+		{"confirmation", &ConfirmationPayload{[]byte{1, 2, 3}}, true},
+		{"empty confirmation", &ConfirmationPayload{}, false},
+	}
+	for _, test := range tests {
+		var tx Transaction
+		err := tx.SetPayload(test.payload)
+		if (err == nil) != test.valid {
+			t.Errorf("%v: SetPayload = %v, want valid %v", test.name, err, test.valid)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		decoded, err := tx.DecodePayload()
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if encoded, _ := decoded.Encode(); encoded != tx.Payload {
+			t.Errorf("%v: round trip changed the payload to %q, want %q", test.name, encoded, tx.Payload)
+		}
+	}
+}
+
+func TestDecodePayloadRefusesMalformedPayloads(t *testing.T) {
+	application := `{"merit":{},"hash":"` + strings.Repeat("ab", 32) + `","timestamp":1,"application_signature":{"r":1,"s":1}}`
+	tests := []struct {
+		name    string
+		txType  string
+		payload string
+	}{
+		{"unknown type", "transfer", ""},
+		{"application that is not json", TYPE_APPLICATION, "merit"},
+		{"application with an unknown field", TYPE_APPLICATION, strings.TrimSuffix(application, "}") + `,"extra":1}`},
+		{"application with trailing data", TYPE_APPLICATION, application + " {}"},
+		{"acceptance that is not base64", TYPE_ACCEPTANCE, "not a key"},
+		{"acceptance that is not a key", TYPE_ACCEPTANCE, "AAAA"},
+		{"confirmation that is not hex", TYPE_CONFIRMATION, "xyz"},
+		{"empty confirmation", TYPE_CONFIRMATION, ""},
+	}
+	for _, test := range tests {
+		tx := Transaction{TXType: test.txType, Payload: test.payload}
+		if payload, err := tx.DecodePayload(); err == nil {
+			t.Errorf("%v: decoded %+v", test.name, payload)
+		}
+	}
+
+	//The payloads above are refused for the listed reason only
+	for _, tx := range []Transaction{
+		{TXType: TYPE_APPLICATION, Payload: application},
+		{TXType: TYPE_CONFIRMATION, Payload: hex.EncodeToString([]byte("identity"))},
+	} {
+		if _, err := tx.DecodePayload(); err != nil {
+			t.Errorf("%v: %v", tx.TXType, err)
+		}
+	}
+}
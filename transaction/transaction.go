@@ -6,22 +6,20 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"math"
 
 	"../models"
 )
 
-// preSignedTransaction represents a Transaction without signature, used for signature verification process
-type preSignedTransaction struct {
-	From      string  `json:"from"`
-	To        string  `json:"to"`
-	TXType    string  `json:"txtype"`
-	TXFee     float32 `json:"txfee"`
-	Timestamp int64   `json:"timestamp"`
-	Payload   string  `json:"payload"`
-}
+// Transaction versions, the chain decides from which height a version is accepted
+const (
+	VERSION_1       = 1
+	CURRENT_VERSION = VERSION_1
+)
 
 // Transaction encapsulate all the data of a transaction
 type Transaction struct {
+	Version   int32                 `json:"version"`
 	From      string                `json:"from"`
 	To        string                `json:"to"`
 	TXType    string                `json:"txtype"`
@@ -53,34 +51,38 @@ func (tx *Transaction) EncodeToJSON() (jsonString string, error error) {
 }
 
 // DecodeFromJSON decode the information of transaction from jsonString into tx struct
-func (tx *Transaction) DecodeFromJSON(jsonString string) {
-	json.Unmarshal([]byte(jsonString), tx)
+func (tx *Transaction) DecodeFromJSON(jsonString string) error {
+	return json.Unmarshal([]byte(jsonString), tx)
 }
 
-// GenHash generates the hash of the tx
+// GenHash generates the hash of the tx from the canonical encoding of its unsigned fields
 func (tx *Transaction) GenHash() string {
-	pstx := new(preSignedTransaction)
-	pstx.From = tx.From
-	pstx.To = tx.To
-	pstx.TXType = tx.TXType
-	pstx.TXFee = tx.TXFee
-	pstx.Timestamp = tx.Timestamp
-	pstx.Payload = tx.Payload
-	bytes, _ := json.Marshal(pstx)
-	pstxstr := string(bytes)
-	hash := sha256.Sum256([]byte(pstxstr))
+	hash := sha256.Sum256(tx.unsignedBytes())
 	return hex.EncodeToString(hash[:])
 }
 
-// Verify will return true if this is a valid transaction in terms of format
+// Verify will return true if this is a valid transaction in terms of format, by the rules of its version
 func (tx *Transaction) Verify() bool {
-	if !tx.verifyHash() {
-		return false
+	switch tx.Version {
+	case VERSION_1:
+		//A NaN fee would pass every comparison with the minimum fee
+		fee := float64(tx.TXFee)
+		if math.IsNaN(fee) || math.IsInf(fee, 0) || fee < 0 {
+			return false
+		}
+		if !tx.verifyHash() {
+			return false
+		}
+		if _, err := tx.DecodePayload(); err != nil {
+			return false
+		}
+		senderPubKey, err := DecodeECDSAPublicKey(tx.From)
+		if err != nil {
+			return false
+		}
+		return tx.verifySignature(senderPubKey)
 	}
-	if !tx.verifySignature(DecodeECDSAPublicKey(tx.From)) {
-		return false
-	}
-	return true
+	return false
 }
 
 func (tx *Transaction) verifyHash() bool {
@@ -88,6 +90,9 @@ func (tx *Transaction) verifyHash() bool {
 }
 
 func (tx *Transaction) verifySignature(senderPubKey *ecdsa.PublicKey) bool {
+	if tx.Signature.R == nil || tx.Signature.S == nil {
+		return false
+	}
 	hashbytes, err := hex.DecodeString(tx.Hash)
 	if err != nil {
 		return false